@@ -0,0 +1,93 @@
+package app
+
+import (
+	"github.com/gin-gonic/gin"
+	"github/jorgemvv01/go-api/config"
+	"github/jorgemvv01/go-api/controllers"
+	"github/jorgemvv01/go-api/logging"
+	"github/jorgemvv01/go-api/metrics"
+	"github/jorgemvv01/go-api/repositories"
+	"github/jorgemvv01/go-api/routes"
+	"github/jorgemvv01/go-api/storage"
+	"github/jorgemvv01/go-api/utils"
+	"gorm.io/gorm"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// App holds the dependencies of the API, built once from the configuration.
+type App struct {
+	Config       *config.Config
+	Logger       *slog.Logger
+	DB           *gorm.DB
+	Metrics      *metrics.Metrics
+	TokenService utils.TokenService
+	Router       *gin.Engine
+	workers      []Worker
+	mu           sync.Mutex
+	running      []*runningWorker
+}
+
+// New opens the configured database and wires the repositories, controllers
+// and routes on top of it. Logs are written as JSON to standard output.
+func New(cfg *config.Config) (*App, error) {
+	logger := logging.New(cfg.LogLevel, os.Stdout)
+	db, err := storage.Open(cfg.Database, logger)
+	if err != nil {
+		return nil, err
+	}
+	a, err := NewWithDB(cfg, db, logger)
+	if err != nil {
+		if sqlDB, dbErr := db.DB(); dbErr == nil {
+			sqlDB.Close()
+		}
+		return nil, err
+	}
+	return a, nil
+}
+
+// NewWithDB wires the API on top of an already opened database.
+func NewWithDB(cfg *config.Config, db *gorm.DB, logger *slog.Logger) (*App, error) {
+	m, err := metrics.New(db)
+	if err != nil {
+		return nil, err
+	}
+	tokenService := utils.NewTokenService([]byte(cfg.Auth.JWTSecret), cfg.Auth.AccessTokenTTL, cfg.Auth.RefreshTokenTTL)
+
+	a := &App{
+		Config:       cfg,
+		Logger:       logger,
+		DB:           db,
+		Metrics:      m,
+		TokenService: tokenService,
+	}
+
+	userRepository := repositories.NewUserRepository(db)
+	rentRepository := repositories.NewRentRepository(db)
+	ctrl := &routes.Controllers{
+		Health: controllers.NewHealthController(a.healthChecks()...),
+		Auth:   controllers.NewAuthController(userRepository, tokenService),
+		User:   controllers.NewUserController(userRepository),
+		Type:   controllers.NewTypeController(repositories.NewTypeRepository(db)),
+		Genre:  controllers.NewGenreController(repositories.NewGenreRepository(db)),
+		Movie:  controllers.NewMovieController(repositories.NewMovieRepository(db)),
+		Copy:   controllers.NewCopyController(repositories.NewCopyRepository(db)),
+		Rent:   controllers.NewRentController(m.RentRepository(rentRepository)),
+	}
+
+	idempotencyRepository := repositories.NewIdempotencyRepository(db, cfg.HTTP.IdempotencyKeyTTL, cfg.HTTP.IdempotencyLease)
+	a.Router = routes.SetupRoutes(ctrl, tokenService, idempotencyRepository, logger, m)
+	a.AddWorker(idempotencyPurger{keys: idempotencyRepository, logger: logger, interval: idempotencyPurgeInterval})
+	a.AddWorker(rentStatusUpdater{rents: rentRepository, logger: logger, interval: rentStatusUpdateInterval})
+	return a, nil
+}
+
+// Close closes the database connections.
+func (a *App) Close() error {
+	sqlDB, err := a.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
@@ -0,0 +1,49 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github/jorgemvv01/go-api/controllers"
+	"github/jorgemvv01/go-api/migrations"
+)
+
+func (a *App) healthChecks() []controllers.HealthCheck {
+	return []controllers.HealthCheck{
+		{Name: "database", Failure: "database unreachable", Check: a.checkDatabase},
+		{Name: "migrations", Failure: "migrations behind", Check: a.checkMigrations},
+		{Name: "workers", Failure: "worker stopped", Check: a.checkWorkers},
+	}
+}
+
+func (a *App) checkDatabase(ctx context.Context) error {
+	sqlDB, err := a.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+func (a *App) checkMigrations(ctx context.Context) error {
+	pending, err := migrations.Pending(a.DB.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%d pending migrations, the first one is %d %s", len(pending), pending[0].Version, pending[0].Name)
+	}
+	return nil
+}
+
+// checkWorkers fails when a background worker stopped while serving.
+func (a *App) checkWorkers(context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, r := range a.running {
+		select {
+		case <-r.exited:
+			return fmt.Errorf("worker %s stopped: %v", r.worker.Name(), r.err)
+		default:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package app
+
+import (
+	"context"
+	"github/jorgemvv01/go-api/repositories"
+	"log/slog"
+	"time"
+)
+
+// idempotencyPurgeInterval is how often the expired idempotency keys are
+// deleted.
+const idempotencyPurgeInterval = time.Hour
+
+// idempotencyPurger deletes the expired idempotency keys in the background.
+// Failures are logged and retried on the next run.
+type idempotencyPurger struct {
+	keys     repositories.IdempotencyRepository
+	logger   *slog.Logger
+	interval time.Duration
+}
+
+func (p idempotencyPurger) Name() string {
+	return "idempotency-purger"
+}
+
+func (p idempotencyPurger) Run(ctx context.Context) error {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			deleted, err := p.keys.DeleteExpired(ctx)
+			if err != nil {
+				p.logger.Error("unable to delete the expired idempotency keys", "error", err)
+				continue
+			}
+			p.logger.Debug("deleted the expired idempotency keys", "deleted", deleted)
+		}
+	}
+}
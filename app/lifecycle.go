@@ -0,0 +1,124 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Worker is a background task that runs along the HTTP server. Run must
+// return once ctx is cancelled.
+type Worker interface {
+	Name() string
+	Run(ctx context.Context) error
+}
+
+type runningWorker struct {
+	worker Worker
+	cancel context.CancelFunc
+	// exited is closed once Run returned err.
+	exited chan struct{}
+	err    error
+}
+
+// AddWorker registers a worker started by Serve. Workers are stopped in the
+// reverse order they were added, after the HTTP server and before the
+// database.
+func (a *App) AddWorker(worker Worker) {
+	a.workers = append(a.workers, worker)
+}
+
+// Run listens on the configured address and serves until ctx is cancelled.
+func (a *App) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", a.Config.HTTP.Address)
+	if err != nil {
+		return fmt.Errorf("unable to listen on %s: %w", a.Config.HTTP.Address, err)
+	}
+	return a.Serve(ctx, listener)
+}
+
+// Serve starts the workers and serves the API on listener until ctx is
+// cancelled or the server fails. It then lets in-flight requests finish
+// within the shutdown timeout, stops the workers and closes the database.
+// An error is returned when anything had to be cut off.
+func (a *App) Serve(ctx context.Context, listener net.Listener) error {
+	server := &http.Server{
+		Handler:      a.Router,
+		ReadTimeout:  a.Config.HTTP.ReadTimeout,
+		WriteTimeout: a.Config.HTTP.WriteTimeout,
+	}
+
+	running := a.startWorkers()
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+	a.Logger.Info("server listening", "address", listener.Addr().String())
+
+	var problems []error
+	select {
+	case err := <-serveErr:
+		problems = append(problems, fmt.Errorf("server failed: %w", err))
+	case <-ctx.Done():
+		a.Logger.Info("shutting down", "timeout", a.Config.HTTP.ShutdownTimeout.String())
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.Config.HTTP.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		_ = server.Close()
+		problems = append(problems, fmt.Errorf("in-flight requests cut off: %w", err))
+	}
+	problems = append(problems, stopWorkers(shutdownCtx, running)...)
+	if err := a.Close(); err != nil {
+		problems = append(problems, fmt.Errorf("unable to close the database: %w", err))
+	}
+	return joinErrors(problems)
+}
+
+func (a *App) startWorkers() []*runningWorker {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.running = make([]*runningWorker, 0, len(a.workers))
+	for _, worker := range a.workers {
+		ctx, cancel := context.WithCancel(context.Background())
+		r := &runningWorker{worker: worker, cancel: cancel, exited: make(chan struct{})}
+		go func() {
+			r.err = r.worker.Run(ctx)
+			close(r.exited)
+		}()
+		a.running = append(a.running, r)
+	}
+	return a.running
+}
+
+func stopWorkers(ctx context.Context, running []*runningWorker) []error {
+	var problems []error
+	for i := len(running) - 1; i >= 0; i-- {
+		r := running[i]
+		r.cancel()
+		select {
+		case <-r.exited:
+			if r.err != nil && !errors.Is(r.err, context.Canceled) {
+				problems = append(problems, fmt.Errorf("worker %s failed: %w", r.worker.Name(), r.err))
+			}
+		case <-ctx.Done():
+			problems = append(problems, fmt.Errorf("worker %s did not stop in time", r.worker.Name()))
+		}
+	}
+	return problems
+}
+
+func joinErrors(problems []error) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	messages := make([]string, 0, len(problems))
+	for _, problem := range problems {
+		messages = append(messages, problem.Error())
+	}
+	return errors.New(strings.Join(messages, "; "))
+}
@@ -0,0 +1,45 @@
+package app
+
+import (
+	"context"
+	"github/jorgemvv01/go-api/models"
+	"github/jorgemvv01/go-api/repositories"
+	"log/slog"
+	"time"
+)
+
+// rentStatusUpdateInterval is how often the statuses the rents reached as
+// time went by are stored.
+const rentStatusUpdateInterval = time.Hour
+
+// rentStatusUpdater stores in the background that reserved rents became
+// active and active rents overdue. The API answers the current status anyway,
+// this keeps the stored one from lagging behind. Failures are logged and
+// retried on the next run.
+type rentStatusUpdater struct {
+	rents    repositories.RentRepository
+	logger   *slog.Logger
+	interval time.Duration
+}
+
+func (u rentStatusUpdater) Name() string {
+	return "rent-status-updater"
+}
+
+func (u rentStatusUpdater) Run(ctx context.Context) error {
+	ticker := time.NewTicker(u.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			updated, err := u.rents.AdvanceStatuses(ctx, time.Now().Format(models.DateLayout))
+			if err != nil {
+				u.logger.Error("unable to update the rent statuses", "error", err)
+				continue
+			}
+			u.logger.Debug("updated the rent statuses", "updated", updated)
+		}
+	}
+}
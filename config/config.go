@@ -0,0 +1,276 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	Database DatabaseConfig `yaml:"database"`
+	HTTP     HTTPConfig     `yaml:"http"`
+	Auth     AuthConfig     `yaml:"auth"`
+	LogLevel string         `yaml:"log_level"`
+}
+
+type DatabaseConfig struct {
+	Driver          string        `yaml:"driver"`
+	DSN             string        `yaml:"dsn"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	AutoMigrate     bool          `yaml:"auto_migrate"`
+}
+
+type HTTPConfig struct {
+	Address           string        `yaml:"address"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"`
+	GinMode           string        `yaml:"gin_mode"`
+	IdempotencyKeyTTL time.Duration `yaml:"idempotency_key_ttl"`
+	IdempotencyLease  time.Duration `yaml:"idempotency_lease"`
+}
+
+type AuthConfig struct {
+	JWTSecret       string        `yaml:"jwt_secret"`
+	AccessTokenTTL  time.Duration `yaml:"access_token_ttl"`
+	RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl"`
+	AdminUsername   string        `yaml:"admin_username"`
+	AdminPassword   string        `yaml:"admin_password"`
+}
+
+const LogLevelDebug = "debug"
+const LogLevelInfo = "info"
+const LogLevelWarn = "warn"
+const LogLevelError = "error"
+
+const DriverPostgres = "postgres"
+const DriverSQLite = "sqlite"
+const DriverMySQL = "mysql"
+
+var drivers = []string{DriverPostgres, DriverSQLite, DriverMySQL}
+var ginModes = []string{"debug", "release", "test"}
+var logLevels = []string{LogLevelDebug, LogLevelInfo, LogLevelWarn, LogLevelError}
+
+func Default() *Config {
+	return &Config{
+		Database: DatabaseConfig{
+			Driver:          DriverPostgres,
+			MaxOpenConns:    10,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: time.Hour,
+			AutoMigrate:     true,
+		},
+		HTTP: HTTPConfig{
+			Address:           ":8080",
+			ReadTimeout:       15 * time.Second,
+			WriteTimeout:      15 * time.Second,
+			ShutdownTimeout:   20 * time.Second,
+			GinMode:           "debug",
+			IdempotencyKeyTTL: 24 * time.Hour,
+			IdempotencyLease:  time.Minute,
+		},
+		Auth: AuthConfig{
+			AccessTokenTTL:  15 * time.Minute,
+			RefreshTokenTTL: 7 * 24 * time.Hour,
+		},
+		LogLevel: LogLevelInfo,
+	}
+}
+
+// Load builds the configuration from the defaults, the YAML file given by
+// the -config flag or CONFIG_FILE, the environment and the command line
+// flags in args, each one overriding the previous ones, and validates it.
+// The arguments left after the flags are returned.
+func Load(args []string) (*Config, []string, error) {
+	flags := flag.NewFlagSet("go-api", flag.ContinueOnError)
+	path := flags.String("config", os.Getenv("CONFIG_FILE"), "YAML configuration file")
+	for name, set := range settings {
+		flags.String(name, "", set.usage+" ($"+set.env+")")
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	cfg := Default()
+	if *path != "" {
+		if err := cfg.loadFile(*path); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return nil, nil, err
+	}
+	var err error
+	flags.Visit(func(f *flag.Flag) {
+		set, ok := settings[f.Name]
+		if !ok || err != nil {
+			return
+		}
+		if err = set.apply(cfg, f.Value.String()); err != nil {
+			err = fmt.Errorf("invalid -%s: %w", f.Name, err)
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return cfg, flags.Args(), nil
+}
+
+func (cfg *Config) loadFile(path string) error {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read config file: %w", err)
+	}
+	if err = yaml.Unmarshal(file, cfg); err != nil {
+		return fmt.Errorf("unable to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (cfg *Config) loadEnv() error {
+	for _, set := range settings {
+		if value, ok := os.LookupEnv(set.env); ok {
+			if err := set.apply(cfg, value); err != nil {
+				return fmt.Errorf("invalid %s: %w", set.env, err)
+			}
+		}
+	}
+	return nil
+}
+
+// setting is a configuration value that can be given both as an
+// environment variable and as a command line flag.
+type setting struct {
+	env   string
+	usage string
+	apply func(cfg *Config, value string) error
+}
+
+var settings = map[string]setting{
+	"db-driver":             {"DATABASE_DRIVER", "database driver (postgres, sqlite or mysql)", setString(func(c *Config) *string { return &c.Database.Driver })},
+	"db-dsn":                {"DATABASE_URL", "database connection string", setString(func(c *Config) *string { return &c.Database.DSN })},
+	"db-max-open-conns":     {"DATABASE_MAX_OPEN_CONNS", "maximum open database connections", setInt(func(c *Config) *int { return &c.Database.MaxOpenConns })},
+	"db-max-idle-conns":     {"DATABASE_MAX_IDLE_CONNS", "maximum idle database connections", setInt(func(c *Config) *int { return &c.Database.MaxIdleConns })},
+	"db-conn-max-lifetime":  {"DATABASE_CONN_MAX_LIFETIME", "maximum lifetime of a database connection", setDuration(func(c *Config) *time.Duration { return &c.Database.ConnMaxLifetime })},
+	"db-auto-migrate":       {"DATABASE_AUTO_MIGRATE", "apply pending migrations when the server starts", setBool(func(c *Config) *bool { return &c.Database.AutoMigrate })},
+	"http-address":          {"HTTP_ADDRESS", "HTTP listen address", setString(func(c *Config) *string { return &c.HTTP.Address })},
+	"http-read-timeout":     {"HTTP_READ_TIMEOUT", "HTTP read timeout", setDuration(func(c *Config) *time.Duration { return &c.HTTP.ReadTimeout })},
+	"http-write-timeout":    {"HTTP_WRITE_TIMEOUT", "HTTP write timeout", setDuration(func(c *Config) *time.Duration { return &c.HTTP.WriteTimeout })},
+	"http-shutdown-timeout": {"HTTP_SHUTDOWN_TIMEOUT", "time given to in-flight requests to finish on shutdown", setDuration(func(c *Config) *time.Duration { return &c.HTTP.ShutdownTimeout })},
+	"idempotency-key-ttl":   {"IDEMPOTENCY_KEY_TTL", "how long responses to requests with an Idempotency-Key are replayed", setDuration(func(c *Config) *time.Duration { return &c.HTTP.IdempotencyKeyTTL })},
+	"idempotency-lease":     {"IDEMPOTENCY_LEASE", "how long an Idempotency-Key stays reserved by a request still being handled", setDuration(func(c *Config) *time.Duration { return &c.HTTP.IdempotencyLease })},
+	"gin-mode":              {"GIN_MODE", "gin mode (debug, release or test)", setString(func(c *Config) *string { return &c.HTTP.GinMode })},
+	"jwt-secret":            {"JWT_SECRET", "secret used to sign the tokens", setString(func(c *Config) *string { return &c.Auth.JWTSecret })},
+	"access-token-ttl":      {"ACCESS_TOKEN_TTL", "access token lifetime", setDuration(func(c *Config) *time.Duration { return &c.Auth.AccessTokenTTL })},
+	"refresh-token-ttl":     {"REFRESH_TOKEN_TTL", "refresh token lifetime", setDuration(func(c *Config) *time.Duration { return &c.Auth.RefreshTokenTTL })},
+	"admin-username":        {"ADMIN_USERNAME", "username of the administrator created at startup", setString(func(c *Config) *string { return &c.Auth.AdminUsername })},
+	"admin-password":        {"ADMIN_PASSWORD", "password of the administrator created at startup", setString(func(c *Config) *string { return &c.Auth.AdminPassword })},
+	"log-level":             {"LOG_LEVEL", "log level (debug, info, warn or error)", setString(func(c *Config) *string { return &c.LogLevel })},
+}
+
+func setString(field func(c *Config) *string) func(cfg *Config, value string) error {
+	return func(cfg *Config, value string) error {
+		*field(cfg) = value
+		return nil
+	}
+}
+
+func setInt(field func(c *Config) *int) func(cfg *Config, value string) error {
+	return func(cfg *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = n
+		return nil
+	}
+}
+
+func setBool(field func(c *Config) *bool) func(cfg *Config, value string) error {
+	return func(cfg *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = b
+		return nil
+	}
+}
+
+func setDuration(field func(c *Config) *time.Duration) func(cfg *Config, value string) error {
+	return func(cfg *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = d
+		return nil
+	}
+}
+
+// Validate reports every invalid setting at once.
+func (cfg *Config) Validate() error {
+	var problems []string
+	if !contains(drivers, cfg.Database.Driver) {
+		problems = append(problems, fmt.Sprintf("database driver must be one of %s", strings.Join(drivers, ", ")))
+	}
+	if cfg.Database.DSN == "" {
+		problems = append(problems, "database dsn is required")
+	}
+	if cfg.Database.MaxOpenConns < 0 || cfg.Database.MaxIdleConns < 0 {
+		problems = append(problems, "database pool sizes must not be negative")
+	}
+	if cfg.Database.MaxOpenConns > 0 && cfg.Database.MaxIdleConns > cfg.Database.MaxOpenConns {
+		problems = append(problems, "database max idle connections must not exceed max open connections")
+	}
+	if cfg.HTTP.Address == "" {
+		problems = append(problems, "http address is required")
+	}
+	if cfg.HTTP.ReadTimeout <= 0 || cfg.HTTP.WriteTimeout <= 0 || cfg.HTTP.ShutdownTimeout <= 0 {
+		problems = append(problems, "http timeouts must be positive")
+	}
+	if cfg.HTTP.IdempotencyKeyTTL <= 0 {
+		problems = append(problems, "idempotency key lifetime must be positive")
+	}
+	if cfg.HTTP.IdempotencyLease <= 0 {
+		problems = append(problems, "idempotency lease must be positive")
+	}
+	if !contains(ginModes, cfg.HTTP.GinMode) {
+		problems = append(problems, fmt.Sprintf("gin mode must be one of %s", strings.Join(ginModes, ", ")))
+	}
+	if cfg.Auth.JWTSecret == "" {
+		problems = append(problems, "jwt secret is required")
+	}
+	if cfg.Auth.AccessTokenTTL <= 0 || cfg.Auth.RefreshTokenTTL <= 0 {
+		problems = append(problems, "token lifetimes must be positive")
+	}
+	if (cfg.Auth.AdminUsername == "") != (cfg.Auth.AdminPassword == "") {
+		problems = append(problems, "admin username and password must be given together")
+	}
+	if !contains(logLevels, cfg.LogLevel) {
+		problems = append(problems, fmt.Sprintf("log level must be one of %s", strings.Join(logLevels, ", ")))
+	}
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github/jorgemvv01/go-api/models"
+	"github/jorgemvv01/go-api/repositories"
+	"github/jorgemvv01/go-api/utils"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+type AuthController interface {
+	Login(c *gin.Context)
+	Refresh(c *gin.Context)
+}
+
+type authController struct {
+	userRepository repositories.UserRepository
+	tokenService   utils.TokenService
+}
+
+func NewAuthController(repository repositories.UserRepository, tokenService utils.TokenService) AuthController {
+	return &authController{
+		userRepository: repository,
+		tokenService:   tokenService,
+	}
+}
+
+// Login
+// @Summary Login
+// @Description Get an access token and a refresh token for a username and password.
+// @Param tags body models.LoginRequest true "Login"
+// @Produce application/json
+// @Tags Auth
+// @Success 200 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /auth/login [post]
+// @Router /v2/auth/login [post]
+func (ac *authController) Login(c *gin.Context) {
+	var login *models.LoginRequest
+	if err := c.ShouldBindJSON(&login); err != nil {
+		abortWithError(c, invalidRequest(err))
+		return
+	}
+	user, err := ac.userRepository.GetByUsername(c.Request.Context(), login.Username)
+	if err != nil && !errors.Is(err, utils.ErrUserNotFound) {
+		abortWithError(c, err)
+		return
+	}
+	if user == nil || user.PasswordHash == "" || !utils.CheckPassword(user.PasswordHash, login.Password) {
+		abortWithError(c, utils.ErrInvalidCredentials)
+		return
+	}
+	ac.issueTokens(c, *user)
+}
+
+// RefreshToken
+// @Summary Refresh token
+// @Description Get a new access token and refresh token with a refresh token.
+// @Param tags body models.RefreshRequest true "Refresh token"
+// @Produce application/json
+// @Tags Auth
+// @Success 200 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /auth/refresh [post]
+// @Router /v2/auth/refresh [post]
+func (ac *authController) Refresh(c *gin.Context) {
+	var refresh *models.RefreshRequest
+	if err := c.ShouldBindJSON(&refresh); err != nil {
+		abortWithError(c, invalidRequest(err))
+		return
+	}
+	claims, err := ac.tokenService.Parse(refresh.RefreshToken, utils.RefreshToken)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	// Reload the user so that a deleted user or a role change takes effect.
+	user, err := ac.userRepository.GetByID(c.Request.Context(), claims.UserID())
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if user.ID == 0 {
+		abortWithError(c, utils.ErrInvalidToken)
+		return
+	}
+	ac.issueTokens(c, models.User{Model: gorm.Model{ID: user.ID}, Role: user.Role})
+}
+
+func (ac *authController) issueTokens(c *gin.Context, user models.User) {
+	tokens, err := ac.tokenService.Generate(user)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, models.Response{
+		Status:  "Success",
+		Message: "Authenticated successfully",
+		Data:    tokens,
+	})
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github/jorgemvv01/go-api/models"
+	"github/jorgemvv01/go-api/repositories"
+	"github/jorgemvv01/go-api/utils"
+	"net/http"
+	"strconv"
+)
+
+type CopyController interface {
+	Create(c *gin.Context)
+	GetByMovieID(c *gin.Context)
+	Delete(c *gin.Context)
+}
+
+type copyController struct {
+	copyRepository repositories.CopyRepository
+}
+
+func NewCopyController(repository repositories.CopyRepository) CopyController {
+	return &copyController{
+		copyRepository: repository,
+	}
+}
+
+// CreateCopy
+// @Summary Create Copy
+// @Description Add a physical copy of a movie to the inventory. Adding the barcode of a deleted copy of the same movie restores it.
+// @Param tags body models.CopyRequest true "Create copy"
+// @Produce application/json
+// @Tags Inventory
+// @Success 200 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /copies/create [post]
+// @Router /v2/copies [post]
+func (cc *copyController) Create(c *gin.Context) {
+	var request models.CopyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, invalidRequest(err))
+		return
+	}
+	movieCopy := models.NewCopy(request)
+	copyResponse, err := cc.copyRepository.Create(c.Request.Context(), movieCopy)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, models.Response{
+		Status:  "Success",
+		Message: "Copy created successfully",
+		Data:    copyResponse,
+	})
+}
+
+// GetCopiesByMovieID
+// @Summary Get Copies by Movie ID
+// @Description Get the physical copies of a movie.
+// @Param ID path string true "Get copies by movie ID"
+// @Produce application/json
+// @Tags Inventory
+// @Success 200 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /movies/{ID}/copies [get]
+// @Router /v2/movies/{ID}/copies [get]
+func (cc *copyController) GetByMovieID(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		abortWithError(c, utils.ErrInvalidID.Withf("Invalid movie ID"))
+		return
+	}
+	copies, err := cc.copyRepository.GetByMovieID(c.Request.Context(), uint(id))
+	if err != nil {
+		if errors.Is(err, utils.ErrMovieNotFound) {
+			abortWithError(c, utils.ErrMovieNotFound.Withf("Movie with ID %d not found", uint(id)))
+		} else {
+			abortWithError(c, err)
+		}
+		return
+	}
+	if len(*copies) == 0 {
+		c.JSON(http.StatusOK, models.Response{
+			Status:  "Success",
+			Message: "No copies found",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, models.Response{
+		Status:  "Success",
+		Message: "Copies found",
+		Data:    copies,
+	})
+}
+
+// DeleteCopy
+// @Summary Delete Copy
+// @Description Remove a physical copy from the inventory. Copies rented or booked for a future rent cannot be removed.
+// @Produce application/json
+// @Param ID path string true "Delete copy by ID"
+// @Tags Inventory
+// @Success 200 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /copies/delete/{ID} [delete]
+// @Router /v2/copies/{ID} [delete]
+func (cc *copyController) Delete(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		abortWithError(c, utils.ErrInvalidID.Withf("Invalid copy ID"))
+		return
+	}
+	if err = cc.copyRepository.Delete(c.Request.Context(), uint(id)); err != nil {
+		if errors.Is(err, utils.ErrNotFound) {
+			abortWithError(c, utils.ErrCopyNotFound.Withf("Copy with ID %d not found", uint(id)))
+		} else {
+			abortWithError(c, err)
+		}
+		return
+	}
+	c.JSON(http.StatusOK, models.Response{
+		Status:  "Success",
+		Message: "Copy deleted successfully",
+	})
+}
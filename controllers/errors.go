@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github/jorgemvv01/go-api/middlewares"
+	"github/jorgemvv01/go-api/models"
+	"github/jorgemvv01/go-api/utils"
+	"io"
+	"strconv"
+)
+
+// abortWithError answers err as a problem, see middlewares.AbortWithError.
+func abortWithError(c *gin.Context, err error) {
+	middlewares.AbortWithError(c, err)
+}
+
+// invalidRequest turns an error binding the body or the query of a request
+// into a validation error detailing the invalid fields.
+func invalidRequest(err error) error {
+	var validationErrors validator.ValidationErrors
+	var typeError *json.UnmarshalTypeError
+	var syntaxError *json.SyntaxError
+	var numError *strconv.NumError
+	switch {
+	case errors.As(err, &validationErrors):
+		var fields []models.FieldError
+		for _, fieldError := range validationErrors {
+			fields = append(fields, models.FieldError{
+				Field:   fieldError.Field(),
+				Code:    fieldError.Tag(),
+				Message: fieldMessage(fieldError),
+			})
+		}
+		return utils.ErrValidation.WithFields(fields...)
+	case errors.As(err, &typeError):
+		return utils.ErrValidation.WithFields(models.FieldError{
+			Field:   typeError.Field,
+			Code:    "type",
+			Message: "must be a " + typeError.Type.Kind().String(),
+		})
+	case errors.As(err, &syntaxError), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return utils.ErrMalformedRequest
+	case errors.As(err, &numError):
+		return utils.ErrValidation.Withf("Invalid number %q in the query parameters", numError.Num)
+	default:
+		return utils.ErrValidation
+	}
+}
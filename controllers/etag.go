@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github/jorgemvv01/go-api/utils"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// etag is the entity tag of the version of a resource.
+func etag(version uint) string {
+	return `"` + strconv.FormatUint(uint64(version), 10) + `"`
+}
+
+// setETag tags the response with the version of the resource.
+func setETag(c *gin.Context, version uint) {
+	c.Header("ETag", etag(version))
+}
+
+// notModified answers 304 Not Modified when If-None-Match lists the version
+// of the resource the client already has.
+func notModified(c *gin.Context, version uint) bool {
+	header := c.GetHeader("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag(version) {
+			c.AbortWithStatus(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatch returns the version of the resource the client read before
+// changing it, from If-Match. The routes require the header, RequireIfMatch
+// answers requests without it. With *, or without the header, it returns 0,
+// which matches any version. Tags that cannot match any version fail.
+func ifMatch(c *gin.Context) (uint, error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+	if len(header) > 2 && strings.HasPrefix(header, `"`) && strings.HasSuffix(header, `"`) {
+		version, err := strconv.ParseUint(header[1:len(header)-1], 10, 64)
+		if err == nil && version > 0 {
+			return uint(version), nil
+		}
+	}
+	return 0, utils.ErrVersionMismatch.Withf("If-Match %s does not match the current version", header)
+}
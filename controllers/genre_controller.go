@@ -2,7 +2,6 @@ package controllers
 
 import (
 	"errors"
-	"fmt"
 	"github.com/gin-gonic/gin"
 	"github/jorgemvv01/go-api/models"
 	"github/jorgemvv01/go-api/repositories"
@@ -16,6 +15,7 @@ type GenreController interface {
 	GetByID(c *gin.Context)
 	GetAll(c *gin.Context)
 	Update(c *gin.Context)
+	Patch(c *gin.Context)
 	Delete(c *gin.Context)
 }
 
@@ -36,23 +36,20 @@ func NewGenreController(genreRepository repositories.GenreRepository) GenreContr
 // @Produce application/json
 // @Tags Movie Genre
 // @Success 200 {object} models.Response{}
-// @Failure 400 {object} models.Response{}
-// @Failure 500 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
 // @Router /genres/create [post]
+// @Router /v2/genres [post]
 func (gc *genreController) Create(c *gin.Context) {
-	var genre *models.Genre
-	if err := c.ShouldBindJSON(&genre); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, models.Response{
-			Status:  "Error",
-			Message: `Invalid request body... ` + err.Error(),
-		})
+	var request models.GenreRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, invalidRequest(err))
 		return
 	}
-	if err := gc.repository.Create(genre); err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, models.Response{
-			Status:  "Error",
-			Message: `Unable to create genre... ` + err.Error(),
-		})
+	genre := models.NewGenre(request)
+	if err := gc.repository.Create(c.Request.Context(), genre); err != nil {
+		abortWithError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, models.Response{
@@ -66,35 +63,33 @@ func (gc *genreController) Create(c *gin.Context) {
 // @Summary Get Genre by ID
 // @Description Get a genre by ID.
 // @Param ID path string true "Get genre by ID"
+// @Param If-None-Match header string false "ETag of the version already read, answered with 304 Not Modified when still current"
 // @Produce application/json
 // @Tags Movie Genre
 // @Success 200 {object} models.Response{}
-// @Failure 400 {object} models.Response{}
-// @Failure 404 {object} models.Response{}
-// @Failure 500 {object} models.Response{}
+// @Header 200 {string} ETag "Version of the resource"
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Router /genres/{ID} [get]
+// @Router /v2/genres/{ID} [get]
 func (gc *genreController) GetByID(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, models.Response{
-			Status:  "Error",
-			Message: "Invalid genre ID",
-		})
+		abortWithError(c, utils.ErrInvalidID.Withf("Invalid genre ID"))
 		return
 	}
-	genre, err := gc.repository.GetByID(uint(id))
+	genre, err := gc.repository.GetByID(c.Request.Context(), uint(id))
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, models.Response{
-			Status:  "Error",
-			Message: `Unable to get genre... ` + err.Error(),
-		})
+		abortWithError(c, err)
 		return
 	}
 	if genre.ID == 0 {
-		c.AbortWithStatusJSON(http.StatusNotFound, models.Response{
-			Status:  "Error",
-			Message: fmt.Sprintf("Genre with ID %d not found", uint(id)),
-		})
+		abortWithError(c, utils.ErrGenreNotFound.Withf("Genre with ID %d not found", uint(id)))
+		return
+	}
+	setETag(c, genre.Version)
+	if notModified(c, genre.Version) {
 		return
 	}
 	c.JSON(http.StatusOK, models.Response{
@@ -107,31 +102,42 @@ func (gc *genreController) GetByID(c *gin.Context) {
 // GetAllGenres
 // @Summary Get all Genres
 // @Description Get all Genres.
+// @Param page query int false "Page number, 100000 at most"
+// @Param page_size query int false "Page size, 100 at most"
+// @Param sort query string false "Comma separated fields to sort by, prefix with - for descending order"
+// @Param name query string false "Filter by name"
 // @Produce application/json
 // @Tags Movie Genre
 // @Success 200 {object} models.Response{}
-// @Failure 500 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Router /genres [get]
+// @Router /v2/genres [get]
 func (gc *genreController) GetAll(c *gin.Context) {
-	genres, err := gc.repository.GetAll()
+	var query models.GenreQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		abortWithError(c, invalidRequest(err))
+		return
+	}
+	query.Normalize()
+	genres, total, err := gc.repository.GetAll(c.Request.Context(), &query)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, models.Response{
-			Status:  "Error",
-			Message: `Unable to get genres... ` + err.Error(),
-		})
+		abortWithError(c, err)
 		return
 	}
 	if len(*genres) == 0 {
 		c.JSON(http.StatusOK, models.Response{
-			Status:  "Success",
-			Message: "No genres found",
+			Status:     "Success",
+			Message:    "No genres found",
+			Pagination: newPagination(c, query.PageQuery, total),
 		})
 		return
 	}
 	c.JSON(http.StatusOK, models.Response{
-		Status:  "Success",
-		Message: "Genres found",
-		Data:    genres,
+		Status:     "Success",
+		Message:    "Genres found",
+		Data:       genres,
+		Pagination: newPagination(c, query.PageQuery, total),
 	})
 }
 
@@ -141,44 +147,95 @@ func (gc *genreController) GetAll(c *gin.Context) {
 // @Produce application/json
 // @Param ID path string true "Update genre by ID"
 // @Param tags body models.GenreRequest true "Update genre"
+// @Param If-Match header string true "ETag of the version being changed, or * to overwrite any version"
 // @Tags Movie Genre
 // @Success 200 {object} models.Response{}
-// @Failure 400 {object} models.Response{}
-// @Failure 404 {object} models.Response{}
-// @Failure 500 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 412 {object} models.Problem
+// @Failure 428 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
 // @Router /genres/update/{ID} [put]
+// @Router /v2/genres/{ID} [put]
 func (gc *genreController) Update(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, models.Response{
-			Status:  "Error",
-			Message: "Invalid genre ID",
-		})
+		abortWithError(c, utils.ErrInvalidID.Withf("Invalid genre ID"))
 		return
 	}
-	var genre *models.Genre
-	if err = c.ShouldBindJSON(&genre); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, models.Response{
-			Status:  "Error",
-			Message: `Invalid request body... ` + err.Error(),
-		})
+	var request models.GenreRequest
+	if err = c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, invalidRequest(err))
+		return
+	}
+	genre := models.NewGenre(request)
+	var version uint
+	if version, err = ifMatch(c); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	var genreResponse *models.GenreResponse
+	if genreResponse, err = gc.repository.Update(c.Request.Context(), uint(id), genre, version); err != nil {
+		if errors.Is(err, utils.ErrNotFound) {
+			abortWithError(c, utils.ErrGenreNotFound.Withf("Genre with ID %d not found", uint(id)))
+		} else {
+			abortWithError(c, err)
+		}
+		return
+	}
+	setETag(c, genreResponse.Version)
+	c.JSON(http.StatusOK, models.Response{
+		Status:  "Success",
+		Message: "Genre updated successfully",
+		Data:    genreResponse,
+	})
+}
+
+// PatchGenre
+// @Summary Patch Genre
+// @Description Update only the given fields of a genre, the body is a JSON merge patch.
+// @Accept application/merge-patch+json
+// @Produce application/json
+// @Param ID path string true "Patch genre by ID"
+// @Param tags body models.GenrePatch true "Patch genre"
+// @Param If-Match header string true "ETag of the version being changed, or * to overwrite any version"
+// @Tags Movie Genre
+// @Success 200 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 415 {object} models.Problem
+// @Failure 412 {object} models.Problem
+// @Failure 428 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /v2/genres/{ID} [patch]
+func (gc *genreController) Patch(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		abortWithError(c, utils.ErrInvalidID.Withf("Invalid genre ID"))
+		return
+	}
+	var patch models.GenrePatch
+	if err = bindMergePatch(c, &patch); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	var version uint
+	if version, err = ifMatch(c); err != nil {
+		abortWithError(c, err)
 		return
 	}
 	var genreResponse *models.GenreResponse
-	if genreResponse, err = gc.repository.Update(uint(id), genre); err != nil {
+	if genreResponse, err = gc.repository.Patch(c.Request.Context(), uint(id), &patch, version); err != nil {
 		if errors.Is(err, utils.ErrNotFound) {
-			c.AbortWithStatusJSON(http.StatusNotFound, models.Response{
-				Status:  "Error",
-				Message: fmt.Sprintf("Genre with ID %d not found", uint(id)),
-			})
+			abortWithError(c, utils.ErrGenreNotFound.Withf("Genre with ID %d not found", uint(id)))
 		} else {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, models.Response{
-				Status:  "Error",
-				Message: `Unable to update genre... ` + err.Error(),
-			})
+			abortWithError(c, err)
 		}
 		return
 	}
+	setETag(c, genreResponse.Version)
 	c.JSON(http.StatusOK, models.Response{
 		Status:  "Success",
 		Message: "Genre updated successfully",
@@ -191,32 +248,33 @@ func (gc *genreController) Update(c *gin.Context) {
 // @Description Delete Genre by ID.
 // @Produce application/json
 // @Param ID path string true "Delete genre by ID"
+// @Param If-Match header string true "ETag of the version being changed, or * to overwrite any version"
 // @Tags Movie Genre
 // @Success 200 {object} models.Response{}
-// @Failure 400 {object} models.Response{}
-// @Failure 404 {object} models.Response{}
-// @Failure 500 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 412 {object} models.Problem
+// @Failure 428 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
 // @Router /genres/delete/{ID} [delete]
+// @Router /v2/genres/{ID} [delete]
 func (gc *genreController) Delete(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, models.Response{
-			Status:  "Error",
-			Message: "Invalid genre ID",
-		})
+		abortWithError(c, utils.ErrInvalidID.Withf("Invalid genre ID"))
+		return
+	}
+	var version uint
+	if version, err = ifMatch(c); err != nil {
+		abortWithError(c, err)
 		return
 	}
-	if err = gc.repository.Delete(uint(id)); err != nil {
+	if err = gc.repository.Delete(c.Request.Context(), uint(id), version); err != nil {
 		if errors.Is(err, utils.ErrNotFound) {
-			c.AbortWithStatusJSON(http.StatusNotFound, models.Response{
-				Status:  "Error",
-				Message: fmt.Sprintf("Genre with ID %d not found", uint(id)),
-			})
+			abortWithError(c, utils.ErrGenreNotFound.Withf("Genre with ID %d not found", uint(id)))
 		} else {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, models.Response{
-				Status:  "Error",
-				Message: `Unable to delete genre...` + err.Error(),
-			})
+			abortWithError(c, err)
 		}
 		return
 	}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github/jorgemvv01/go-api/logging"
+	"github/jorgemvv01/go-api/models"
+	"net/http"
+	"time"
+)
+
+const healthCheckTimeout = 2 * time.Second
+
+// HealthCheck is a dependency the service needs to serve requests. Failure
+// is answered when the check fails, the error itself is only logged.
+type HealthCheck struct {
+	Name    string
+	Failure string
+	Check   func(ctx context.Context) error
+}
+
+type HealthController interface {
+	Liveness(c *gin.Context)
+	Readiness(c *gin.Context)
+}
+
+type healthController struct {
+	checks []HealthCheck
+}
+
+func NewHealthController(checks ...HealthCheck) HealthController {
+	return &healthController{
+		checks: checks,
+	}
+}
+
+// Liveness reports that the process is running, it checks no dependency.
+func (hc *healthController) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, models.HealthResponse{
+		Status: "ok",
+	})
+}
+
+// Readiness runs every check concurrently and reports each one with its
+// latency, the service is ready when all of them pass.
+func (hc *healthController) Readiness(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), healthCheckTimeout)
+	defer cancel()
+
+	results := make([]models.HealthCheckResponse, len(hc.checks))
+	done := make(chan struct{})
+	for i, check := range hc.checks {
+		go func(i int, check HealthCheck) {
+			start := time.Now()
+			err := check.Check(ctx)
+			results[i] = models.HealthCheckResponse{
+				Status:    "ok",
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				results[i].Status = "failed"
+				results[i].Error = check.Failure
+				logging.FromContext(ctx).Error("readiness check failed", "check", check.Name, "error", err)
+			}
+			done <- struct{}{}
+		}(i, check)
+	}
+	for range hc.checks {
+		<-done
+	}
+
+	response := models.HealthResponse{
+		Status: "ok",
+		Checks: make(map[string]models.HealthCheckResponse, len(hc.checks)),
+	}
+	status := http.StatusOK
+	for i, check := range hc.checks {
+		response.Checks[check.Name] = results[i]
+		if results[i].Status != "ok" {
+			response.Status = "failed"
+			status = http.StatusServiceUnavailable
+		}
+	}
+	c.JSON(status, response)
+}
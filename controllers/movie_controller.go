@@ -2,21 +2,21 @@ package controllers
 
 import (
 	"errors"
-	"fmt"
 	"github.com/gin-gonic/gin"
 	"github/jorgemvv01/go-api/models"
 	"github/jorgemvv01/go-api/repositories"
 	"github/jorgemvv01/go-api/utils"
 	"net/http"
 	"strconv"
-	"time"
 )
 
 type MovieController interface {
 	Create(c *gin.Context)
 	GetByID(c *gin.Context)
 	GetAll(c *gin.Context)
+	Search(c *gin.Context)
 	Update(c *gin.Context)
+	Patch(c *gin.Context)
 	Delete(c *gin.Context)
 }
 
@@ -37,42 +37,20 @@ func NewMovieController(repository repositories.MovieRepository) MovieController
 // @Produce application/json
 // @Tags Movies
 // @Success 200 {object} models.Response{}
-// @Failure 400 {object} models.Response{}
-// @Failure 500 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
 // @Router /movies/create [post]
+// @Router /v2/movies [post]
 func (mc *movieController) Create(c *gin.Context) {
-	var movie *models.Movie
-	if err := c.ShouldBindJSON(&movie); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, models.Response{
-			Status:  "Error",
-			Message: `Invalid request body... ` + err.Error(),
-		})
-		return
-	}
-
-	releaseDate, err := time.Parse("2006-01-02", movie.ReleaseDate)
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, models.Response{
-			Status:  "Error",
-			Message: `Invalid release date... ` + err.Error(),
-		})
+	var request models.MovieRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, invalidRequest(err))
 		return
 	}
-	movie.ReleaseDate = releaseDate.Format("2006-01-02")
-
-	movieResponse, err := mc.movieRepository.Create(movie)
+	movieResponse, err := mc.movieRepository.Create(c.Request.Context(), models.NewMovie(request))
 	if err != nil {
-		if errors.Is(err, utils.ErrGenreNotFound) || errors.Is(err, utils.ErrTypeNotFound) {
-			c.AbortWithStatusJSON(http.StatusNotFound, models.Response{
-				Status:  "Error",
-				Message: `Unable to create movie... ` + err.Error(),
-			})
-		} else {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, models.Response{
-				Status:  "Error",
-				Message: `Unable to create movie... ` + err.Error(),
-			})
-		}
+		abortWithError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, models.Response{
@@ -86,35 +64,33 @@ func (mc *movieController) Create(c *gin.Context) {
 // @Summary Get Movie by ID
 // @Description Get a movie by ID.
 // @Param ID path string true "Get movie by ID"
+// @Param If-None-Match header string false "ETag of the version already read, answered with 304 Not Modified when still current"
 // @Produce application/json
 // @Tags Movies
 // @Success 200 {object} models.Response{}
-// @Failure 400 {object} models.Response{}
-// @Failure 404 {object} models.Response{}
-// @Failure 500 {object} models.Response{}
+// @Header 200 {string} ETag "Version of the resource"
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Router /movies/{ID} [get]
+// @Router /v2/movies/{ID} [get]
 func (mc *movieController) GetByID(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, models.Response{
-			Status:  "Error",
-			Message: "Invalid movie ID",
-		})
+		abortWithError(c, utils.ErrInvalidID.Withf("Invalid movie ID"))
 		return
 	}
-	movie, err := mc.movieRepository.GetByID(uint(id))
+	movie, err := mc.movieRepository.GetByID(c.Request.Context(), uint(id))
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, models.Response{
-			Status:  "Error",
-			Message: `Unable to get movie... ` + err.Error(),
-		})
+		abortWithError(c, err)
 		return
 	}
 	if movie.ID == 0 {
-		c.AbortWithStatusJSON(http.StatusNotFound, models.Response{
-			Status:  "Error",
-			Message: fmt.Sprintf("Movie with ID %d not found", uint(id)),
-		})
+		abortWithError(c, utils.ErrMovieNotFound.Withf("Movie with ID %d not found", uint(id)))
+		return
+	}
+	setETag(c, movie.Version)
+	if notModified(c, movie.Version) {
 		return
 	}
 	c.JSON(http.StatusOK, models.Response{
@@ -127,31 +103,87 @@ func (mc *movieController) GetByID(c *gin.Context) {
 // GetAllMovies
 // @Summary Get all Movies
 // @Description Get all Movies.
+// @Param page query int false "Page number, 100000 at most"
+// @Param page_size query int false "Page size, 100 at most"
+// @Param sort query string false "Comma separated fields to sort by, prefix with - for descending order"
+// @Param genre_id query int false "Filter by genre ID"
+// @Param type_id query int false "Filter by type ID"
+// @Param min_price query number false "Minimum price"
+// @Param max_price query number false "Maximum price"
+// @Param release_year query int false "Filter by release year"
 // @Produce application/json
 // @Tags Movies
 // @Success 200 {object} models.Response{}
-// @Failure 500 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Router /movies [get]
+// @Router /v2/movies [get]
 func (mc *movieController) GetAll(c *gin.Context) {
-	movies, err := mc.movieRepository.GetAll()
+	var query models.MovieQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		abortWithError(c, invalidRequest(err))
+		return
+	}
+	query.Normalize()
+	movies, total, err := mc.movieRepository.GetAll(c.Request.Context(), &query)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, models.Response{
-			Status:  "Error",
-			Message: `Unable to get movies... ` + err.Error(),
+		abortWithError(c, err)
+		return
+	}
+	if len(*movies) == 0 {
+		c.JSON(http.StatusOK, models.Response{
+			Status:     "Success",
+			Message:    "No movies found",
+			Pagination: newPagination(c, query.PageQuery, total),
 		})
 		return
 	}
+	c.JSON(http.StatusOK, models.Response{
+		Status:     "Success",
+		Message:    "Movies found",
+		Data:       movies,
+		Pagination: newPagination(c, query.PageQuery, total),
+	})
+}
+
+// SearchMovies
+// @Summary Search Movies
+// @Description Search movies by name and overview. Results are ranked by relevance and include the matched snippet of the overview.
+// @Param q query string true "Search query"
+// @Param page query int false "Page number, 100000 at most"
+// @Param page_size query int false "Page size, 100 at most"
+// @Produce application/json
+// @Tags Movies
+// @Success 200 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /movies/search [get]
+// @Router /v2/movies/search [get]
+func (mc *movieController) Search(c *gin.Context) {
+	var query models.MovieSearchQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		abortWithError(c, invalidRequest(err))
+		return
+	}
+	query.Normalize()
+	movies, total, err := mc.movieRepository.Search(c.Request.Context(), &query)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
 	if len(*movies) == 0 {
 		c.JSON(http.StatusOK, models.Response{
-			Status:  "Success",
-			Message: "No movies found",
+			Status:     "Success",
+			Message:    "No movies found",
+			Pagination: newPagination(c, query.PageQuery, total),
 		})
 		return
 	}
 	c.JSON(http.StatusOK, models.Response{
-		Status:  "Success",
-		Message: "Movies found",
-		Data:    movies,
+		Status:     "Success",
+		Message:    "Movies found",
+		Data:       movies,
+		Pagination: newPagination(c, query.PageQuery, total),
 	})
 }
 
@@ -161,44 +193,95 @@ func (mc *movieController) GetAll(c *gin.Context) {
 // @Produce application/json
 // @Param ID path string true "Update movie by ID"
 // @Param tags body models.MovieRequest true "Update movie"
+// @Param If-Match header string true "ETag of the version being changed, or * to overwrite any version"
 // @Tags Movies
 // @Success 200 {object} models.Response{}
-// @Failure 400 {object} models.Response{}
-// @Failure 404 {object} models.Response{}
-// @Failure 500 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 412 {object} models.Problem
+// @Failure 428 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
 // @Router /movies/update/{ID} [put]
+// @Router /v2/movies/{ID} [put]
 func (mc *movieController) Update(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, models.Response{
-			Status:  "Error",
-			Message: "Invalid movie ID",
-		})
+		abortWithError(c, utils.ErrInvalidID.Withf("Invalid movie ID"))
 		return
 	}
-	var movie *models.Movie
-	if err = c.ShouldBindJSON(&movie); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, models.Response{
-			Status:  "Error",
-			Message: `Invalid request body... ` + err.Error(),
-		})
+	var request models.MovieRequest
+	if err = c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, invalidRequest(err))
+		return
+	}
+	movie := models.NewMovie(request)
+	var version uint
+	if version, err = ifMatch(c); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	var movieResponse *models.MovieResponse
+	if movieResponse, err = mc.movieRepository.Update(c.Request.Context(), uint(id), movie, version); err != nil {
+		if errors.Is(err, utils.ErrNotFound) {
+			abortWithError(c, utils.ErrMovieNotFound.Withf("Movie with ID %d not found", uint(id)))
+		} else {
+			abortWithError(c, err)
+		}
+		return
+	}
+	setETag(c, movieResponse.Version)
+	c.JSON(http.StatusOK, models.Response{
+		Status:  "Success",
+		Message: "Movie updated successfully",
+		Data:    movieResponse,
+	})
+}
+
+// PatchMovie
+// @Summary Patch Movie
+// @Description Update only the given fields of a movie, the body is a JSON merge patch.
+// @Accept application/merge-patch+json
+// @Produce application/json
+// @Param ID path string true "Patch movie by ID"
+// @Param tags body models.MoviePatch true "Patch movie"
+// @Param If-Match header string true "ETag of the version being changed, or * to overwrite any version"
+// @Tags Movies
+// @Success 200 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 415 {object} models.Problem
+// @Failure 412 {object} models.Problem
+// @Failure 428 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /v2/movies/{ID} [patch]
+func (mc *movieController) Patch(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		abortWithError(c, utils.ErrInvalidID.Withf("Invalid movie ID"))
+		return
+	}
+	var patch models.MoviePatch
+	if err = bindMergePatch(c, &patch); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	var version uint
+	if version, err = ifMatch(c); err != nil {
+		abortWithError(c, err)
 		return
 	}
 	var movieResponse *models.MovieResponse
-	if movieResponse, err = mc.movieRepository.Update(uint(id), movie); err != nil {
+	if movieResponse, err = mc.movieRepository.Patch(c.Request.Context(), uint(id), &patch, version); err != nil {
 		if errors.Is(err, utils.ErrNotFound) {
-			c.AbortWithStatusJSON(http.StatusNotFound, models.Response{
-				Status:  "Error",
-				Message: fmt.Sprintf("Movie with ID %d not found", uint(id)),
-			})
+			abortWithError(c, utils.ErrMovieNotFound.Withf("Movie with ID %d not found", uint(id)))
 		} else {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, models.Response{
-				Status:  "Error",
-				Message: `Unable to update movie... ` + err.Error(),
-			})
+			abortWithError(c, err)
 		}
 		return
 	}
+	setETag(c, movieResponse.Version)
 	c.JSON(http.StatusOK, models.Response{
 		Status:  "Success",
 		Message: "Movie updated successfully",
@@ -211,32 +294,33 @@ func (mc *movieController) Update(c *gin.Context) {
 // @Description Delete Movie by ID.
 // @Produce application/json
 // @Param ID path string true "Delete Movie by ID"
+// @Param If-Match header string true "ETag of the version being changed, or * to overwrite any version"
 // @Tags Movies
 // @Success 200 {object} models.Response{}
-// @Failure 400 {object} models.Response{}
-// @Failure 404 {object} models.Response{}
-// @Failure 500 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 412 {object} models.Problem
+// @Failure 428 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
 // @Router /movies/delete/{ID} [delete]
+// @Router /v2/movies/{ID} [delete]
 func (mc *movieController) Delete(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, models.Response{
-			Status:  "Error",
-			Message: "Invalid movie ID",
-		})
+		abortWithError(c, utils.ErrInvalidID.Withf("Invalid movie ID"))
+		return
+	}
+	var version uint
+	if version, err = ifMatch(c); err != nil {
+		abortWithError(c, err)
 		return
 	}
-	if err = mc.movieRepository.Delete(uint(id)); err != nil {
+	if err = mc.movieRepository.Delete(c.Request.Context(), uint(id), version); err != nil {
 		if errors.Is(err, utils.ErrNotFound) {
-			c.AbortWithStatusJSON(http.StatusNotFound, models.Response{
-				Status:  "Error",
-				Message: fmt.Sprintf("Movie with ID %d not found", uint(id)),
-			})
+			abortWithError(c, utils.ErrMovieNotFound.Withf("Movie with ID %d not found", uint(id)))
 		} else {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, models.Response{
-				Status:  "Error",
-				Message: `Unable to delete movie...` + err.Error(),
-			})
+			abortWithError(c, err)
 		}
 		return
 	}
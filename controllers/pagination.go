@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github/jorgemvv01/go-api/models"
+	"strconv"
+)
+
+// newPagination builds the pagination of a response with the links to the
+// next and previous pages, keeping the rest of the request query.
+func newPagination(c *gin.Context, pageQuery models.PageQuery, total int64) *models.Pagination {
+	pagination := models.NewPagination(pageQuery, total)
+	if pageQuery.Page < pagination.TotalPages {
+		pagination.Next = pageURL(c, pageQuery.Page+1)
+	}
+	if pageQuery.Page > 1 && pagination.TotalPages > 0 {
+		var prev = pageQuery.Page - 1
+		if prev > pagination.TotalPages {
+			prev = pagination.TotalPages
+		}
+		pagination.Prev = pageURL(c, prev)
+	}
+	return pagination
+}
+
+func pageURL(c *gin.Context, page int) string {
+	values := c.Request.URL.Query()
+	values.Set("page", strconv.Itoa(page))
+	return c.Request.URL.Path + "?" + values.Encode()
+}
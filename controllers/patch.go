@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github/jorgemvv01/go-api/models"
+	"github/jorgemvv01/go-api/utils"
+	"io"
+	"sort"
+)
+
+// MergePatchContentType is the content type of JSON merge patches, RFC 7396.
+const MergePatchContentType = "application/merge-patch+json"
+
+// bindMergePatch decodes the JSON merge patch in the body of the request into
+// patch and validates it. Plain JSON is accepted as well. Every patchable
+// field is mandatory, so removing one with null is rejected.
+func bindMergePatch(c *gin.Context, patch interface{}) error {
+	if contentType := c.ContentType(); contentType != MergePatchContentType && contentType != binding.MIMEJSON {
+		return utils.ErrUnsupportedMediaType.Withf("Send the patch as %s", MergePatchContentType)
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return invalidRequest(err)
+	}
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(body, &fields); err != nil {
+		var typeError *json.UnmarshalTypeError
+		if errors.As(err, &typeError) {
+			return utils.ErrMalformedRequest.Withf("The patch must be a JSON object")
+		}
+		return invalidRequest(err)
+	}
+	var nulls []models.FieldError
+	for name, value := range fields {
+		if bytes.Equal(bytes.TrimSpace(value), []byte("null")) {
+			nulls = append(nulls, models.FieldError{Field: name, Code: "required", Message: "cannot be removed"})
+		}
+	}
+	if len(nulls) > 0 {
+		sort.Slice(nulls, func(i, j int) bool { return nulls[i].Field < nulls[j].Field })
+		return utils.ErrValidation.WithFields(nulls...)
+	}
+	if err = binding.JSON.BindBody(body, patch); err != nil {
+		return invalidRequest(err)
+	}
+	return nil
+}
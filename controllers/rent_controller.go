@@ -3,15 +3,23 @@ package controllers
 import (
 	"errors"
 	"github.com/gin-gonic/gin"
+	"github/jorgemvv01/go-api/middlewares"
 	"github/jorgemvv01/go-api/models"
 	"github/jorgemvv01/go-api/repositories"
 	"github/jorgemvv01/go-api/utils"
 	"net/http"
+	"strconv"
 	"time"
 )
 
 type RentController interface {
 	Create(c *gin.Context)
+	GetByID(c *gin.Context)
+	GetAll(c *gin.Context)
+	GetByUserID(c *gin.Context)
+	Return(c *gin.Context)
+	Cancel(c *gin.Context)
+	Extend(c *gin.Context)
 }
 
 type rentController struct {
@@ -26,67 +34,301 @@ func NewRentController(repository repositories.RentRepository) RentController {
 
 // CreateRent
 // @Summary Create rent
-// @Description Create a new rent.
+// @Description Create a new rent. Retries sent with the same Idempotency-Key are answered the original response instead of creating another rent.
 // @Param tags body models.RentRequest true "Create rent"
+// @Param Idempotency-Key header string false "Unique key of the request, reused by its retries"
 // @Produce application/json
 // @Tags Rent
 // @Success 200 {object} models.Response{}
-// @Failure 400 {object} models.Response{}
-// @Failure 500 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 422 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
 // @Router /rent/create [post]
+// @Router /v2/rents [post]
 func (rc *rentController) Create(c *gin.Context) {
 	var rent *models.RentRequest
 	if err := c.ShouldBindJSON(&rent); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, models.Response{
-			Status:  "Error",
-			Message: `Invalid request body... ` + err.Error(),
-		})
+		abortWithError(c, invalidRequest(err))
+		return
+	}
+
+	if !isOwnUser(c, rent.UserID) {
+		abortWithError(c, utils.ErrForbidden.Withf("You can only create rents for yourself"))
 		return
 	}
 
-	startDate, err := time.Parse("2006-01-02", rent.StartDate)
+	rentResponse, err := rc.rentRepository.Create(c.Request.Context(), rent, rent.Days())
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, models.Response{
-			Status:  "Error",
-			Message: `Invalid start date... ` + err.Error(),
-		})
+		abortWithError(c, err)
 		return
 	}
-	endDate, err := time.Parse("2006-01-02", rent.EndDate)
+	c.JSON(http.StatusOK, models.Response{
+		Status:  "Success",
+		Message: "Rent created successfully",
+		Data:    rentResponse,
+	})
+}
+
+// GetRentByID
+// @Summary Get Rent by ID
+// @Description Get a rent by ID with its movies.
+// @Param ID path string true "Get rent by ID"
+// @Produce application/json
+// @Tags Rent
+// @Success 200 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /rent/{ID} [get]
+// @Router /v2/rents/{ID} [get]
+func (rc *rentController) GetByID(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, models.Response{
-			Status:  "Error",
-			Message: `Invalid end date... ` + err.Error(),
-		})
+		abortWithError(c, utils.ErrInvalidID.Withf("Invalid rent ID"))
+		return
+	}
+	rent, err := rc.rentRepository.GetByID(c.Request.Context(), uint(id))
+	if err != nil {
+		if errors.Is(err, utils.ErrRentNotFound) {
+			abortWithError(c, utils.ErrRentNotFound.Withf("Rent with ID %d not found", uint(id)))
+		} else {
+			abortWithError(c, err)
+		}
 		return
 	}
-	if startDate.After(endDate) {
-		c.AbortWithStatusJSON(http.StatusBadRequest, models.Response{
-			Status:  "Error",
-			Message: "The end date must be greater than the start date",
+	if !isOwnUser(c, rent.UserID) {
+		abortWithError(c, utils.ErrForbidden.Withf("You can only access your own rents"))
+		return
+	}
+	c.JSON(http.StatusOK, models.Response{
+		Status:  "Success",
+		Message: "Rent found",
+		Data:    rent,
+	})
+}
+
+// GetAllRents
+// @Summary Get all Rents
+// @Description Get all Rents with their movies. Customers only get their own rents.
+// @Param page query int false "Page number, 100000 at most"
+// @Param page_size query int false "Page size, 100 at most"
+// @Param sort query string false "Comma separated fields to sort by, prefix with - for descending order"
+// @Param user_id query int false "Filter by user ID"
+// @Param status query string false "Filter by status" Enums(reserved, active, returned, overdue, cancelled)
+// @Produce application/json
+// @Tags Rent
+// @Success 200 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /rent [get]
+// @Router /v2/rents [get]
+func (rc *rentController) GetAll(c *gin.Context) {
+	var query models.RentQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		abortWithError(c, invalidRequest(err))
+		return
+	}
+	query.Normalize()
+	if middlewares.IsCustomer(c) {
+		query.UserID, _, _ = middlewares.CurrentUser(c)
+	}
+	rents, total, err := rc.rentRepository.GetAll(c.Request.Context(), &query)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if len(*rents) == 0 {
+		c.JSON(http.StatusOK, models.Response{
+			Status:     "Success",
+			Message:    "No rents found",
+			Pagination: newPagination(c, query.PageQuery, total),
 		})
 		return
 	}
-	var days = endDate.Sub(startDate) / (24 * time.Hour)
+	c.JSON(http.StatusOK, models.Response{
+		Status:     "Success",
+		Message:    "Rents found",
+		Data:       rents,
+		Pagination: newPagination(c, query.PageQuery, total),
+	})
+}
 
-	rentResponse, err := rc.rentRepository.Create(rent, int(days))
+// GetRentsByUserID
+// @Summary Get Rents by User ID
+// @Description Get the rent history of a user.
+// @Param ID path string true "Get rents by user ID"
+// @Param page query int false "Page number, 100000 at most"
+// @Param page_size query int false "Page size, 100 at most"
+// @Param sort query string false "Comma separated fields to sort by, prefix with - for descending order"
+// @Produce application/json
+// @Tags Rent
+// @Success 200 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /users/{ID}/rents [get]
+// @Router /v2/users/{ID}/rents [get]
+func (rc *rentController) GetByUserID(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
-		if errors.Is(err, utils.ErrMovieNotFound) || errors.Is(err, utils.ErrUserNotFound) {
-			c.AbortWithStatusJSON(http.StatusNotFound, models.Response{
-				Status:  "Error",
-				Message: `Unable to create rent... ` + err.Error(),
-			})
+		abortWithError(c, utils.ErrInvalidID.Withf("Invalid user ID"))
+		return
+	}
+	if !isOwnUser(c, uint(id)) {
+		abortWithError(c, utils.ErrForbidden.Withf("You can only access your own rents"))
+		return
+	}
+	var pageQuery models.PageQuery
+	if err = c.ShouldBindQuery(&pageQuery); err != nil {
+		abortWithError(c, invalidRequest(err))
+		return
+	}
+	pageQuery.Normalize()
+	rents, total, err := rc.rentRepository.GetByUserID(c.Request.Context(), uint(id), &pageQuery)
+	if err != nil {
+		if errors.Is(err, utils.ErrUserNotFound) {
+			abortWithError(c, utils.ErrUserNotFound.Withf("User with ID %d not found", uint(id)))
 		} else {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, models.Response{
-				Status:  "Error",
-				Message: `Unable to create rent... ` + err.Error(),
-			})
+			abortWithError(c, err)
 		}
 		return
 	}
+	if len(*rents) == 0 {
+		c.JSON(http.StatusOK, models.Response{
+			Status:     "Success",
+			Message:    "No rents found",
+			Pagination: newPagination(c, pageQuery, total),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, models.Response{
+		Status:     "Success",
+		Message:    "Rents found",
+		Data:       rents,
+		Pagination: newPagination(c, pageQuery, total),
+	})
+}
+
+// ReturnRent
+// @Summary Return rent
+// @Description Return the movies of a rent, today or on a past date not before the start date nor the last return. Movies returned after the end date are charged a late fee according to their type.
+// @Param ID path string true "Return rent by ID"
+// @Param tags body models.RentReturnRequest true "Return rent"
+// @Produce application/json
+// @Tags Rent
+// @Success 200 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /rent/{ID}/return [post]
+// @Router /v2/rents/{ID}/return [post]
+func (rc *rentController) Return(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		abortWithError(c, utils.ErrInvalidID.Withf("Invalid rent ID"))
+		return
+	}
+	var rentReturn *models.RentReturnRequest
+	if err = c.ShouldBindJSON(&rentReturn); err != nil {
+		abortWithError(c, invalidRequest(err))
+		return
+	}
+
+	if rentReturn.ReturnDate == "" {
+		rentReturn.ReturnDate = time.Now().Format(models.DateLayout)
+	}
+
+	rentResponse, err := rc.rentRepository.Return(c.Request.Context(), uint(id), rentReturn)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
 	c.JSON(http.StatusOK, models.Response{
 		Status:  "Success",
-		Message: "Rent created successfully",
+		Message: "Rent returned successfully",
+		Data:    rentResponse,
+	})
+}
+
+// CancelRent
+// @Summary Cancel rent
+// @Description Cancel a reserved or active rent, freeing its copies. Rents cancelled before their start date are refunded in full, the rest are not refunded.
+// @Param ID path string true "Cancel rent by ID"
+// @Produce application/json
+// @Tags Rent
+// @Success 200 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /rent/{ID}/cancel [post]
+// @Router /v2/rents/{ID}/cancel [post]
+func (rc *rentController) Cancel(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		abortWithError(c, utils.ErrInvalidID.Withf("Invalid rent ID"))
+		return
+	}
+
+	rentResponse, err := rc.rentRepository.Cancel(c.Request.Context(), uint(id), time.Now().Format(models.DateLayout))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, models.Response{
+		Status:  "Success",
+		Message: "Rent cancelled successfully",
+		Data:    rentResponse,
+	})
+}
+
+// ExtendRent
+// @Summary Extend rent
+// @Description Move the end date of a reserved or active rent, keeping the copies of the movies not returned yet. The extra days are charged following the pricing of each movie type and recorded as an extension of the rent.
+// @Param ID path string true "Extend rent by ID"
+// @Param tags body models.RentExtensionRequest true "Extend rent"
+// @Produce application/json
+// @Tags Rent
+// @Success 200 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /rent/{ID}/extend [post]
+// @Router /v2/rents/{ID}/extend [post]
+func (rc *rentController) Extend(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		abortWithError(c, utils.ErrInvalidID.Withf("Invalid rent ID"))
+		return
+	}
+	var extension *models.RentExtensionRequest
+	if err = c.ShouldBindJSON(&extension); err != nil {
+		abortWithError(c, invalidRequest(err))
+		return
+	}
+
+	rentResponse, err := rc.rentRepository.Extend(c.Request.Context(), uint(id), extension.EndDate)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, models.Response{
+		Status:  "Success",
+		Message: "Rent extended successfully",
 		Data:    rentResponse,
 	})
 }
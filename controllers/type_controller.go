@@ -2,7 +2,6 @@ package controllers
 
 import (
 	"errors"
-	"fmt"
 	"github.com/gin-gonic/gin"
 	"github/jorgemvv01/go-api/models"
 	"github/jorgemvv01/go-api/repositories"
@@ -17,6 +16,7 @@ type TypeController interface {
 	GetAll(c *gin.Context)
 	Update(c *gin.Context)
 	Delete(c *gin.Context)
+	GetAudits(c *gin.Context)
 }
 
 type typeController struct {
@@ -29,20 +29,27 @@ func NewTypeController(repository repositories.TypeRepository) TypeController {
 	}
 }
 
+// CreateType
+// @Summary Create Type
+// @Description Create a new movie type with its pricing rule.
+// @Param tags body models.TypeRequest true "Create type"
+// @Produce application/json
+// @Tags Movie Type
+// @Success 200 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /types/create [post]
+// @Router /v2/types [post]
 func (tc *typeController) Create(c *gin.Context) {
-	var movieType *models.Type
-	if err := c.ShouldBindJSON(&movieType); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, models.Response{
-			Status:  "Error",
-			Message: `Invalid request body... ` + err.Error(),
-		})
+	var request models.TypeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, invalidRequest(err))
 		return
 	}
-	if err := tc.typeRepository.Create(movieType); err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, models.Response{
-			Status:  "Error",
-			Message: `Unable to create type... ` + err.Error(),
-		})
+	movieType := models.NewType(request)
+	if err := tc.typeRepository.Create(c.Request.Context(), movieType); err != nil {
+		abortWithError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, models.Response{
@@ -56,35 +63,33 @@ func (tc *typeController) Create(c *gin.Context) {
 // @Summary Get Type by ID
 // @Description Get Type by ID
 // @Param ID path string true "Get Type by ID"
+// @Param If-None-Match header string false "ETag of the version already read, answered with 304 Not Modified when still current"
 // @Produce application/json
 // @Tags Movie Type
 // @Success 200 {object} models.Response{}
-// @Failure 400 {object} models.Response{}
-// @Failure 404 {object} models.Response{}
-// @Failure 500 {object} models.Response{}
+// @Header 200 {string} ETag "Version of the resource"
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Router /types/{ID} [get]
+// @Router /v2/types/{ID} [get]
 func (tc *typeController) GetByID(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, models.Response{
-			Status:  "Error",
-			Message: "Invalid type ID",
-		})
+		abortWithError(c, utils.ErrInvalidID.Withf("Invalid type ID"))
 		return
 	}
 	var movieType *models.TypeResponse
-	if movieType, err = tc.typeRepository.GetByID(uint(id)); err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, models.Response{
-			Status:  "Success",
-			Message: `Unable to get type... ` + err.Error(),
-		})
+	if movieType, err = tc.typeRepository.GetByID(c.Request.Context(), uint(id)); err != nil {
+		abortWithError(c, err)
 		return
 	}
 	if movieType.ID == 0 {
-		c.AbortWithStatusJSON(http.StatusNotFound, models.Response{
-			Status:  "Error",
-			Message: fmt.Sprintf("Type with ID %d not found", uint(id)),
-		})
+		abortWithError(c, utils.ErrTypeNotFound.Withf("Type with ID %d not found", uint(id)))
+		return
+	}
+	setETag(c, movieType.Version)
+	if notModified(c, movieType.Version) {
 		return
 	}
 	c.JSON(http.StatusOK, models.Response{
@@ -97,67 +102,89 @@ func (tc *typeController) GetByID(c *gin.Context) {
 // CreateType
 // @Summary Get all Types
 // @Description Get all Types
+// @Param page query int false "Page number, 100000 at most"
+// @Param page_size query int false "Page size, 100 at most"
+// @Param sort query string false "Comma separated fields to sort by, prefix with - for descending order"
+// @Param name query string false "Filter by name"
 // @Produce application/json
 // @Tags Movie Type
 // @Success 200 {object} models.Response{}
-// @Failure 400 {object} models.Response{}
-// @Failure 500 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Router /types/ [get]
+// @Router /v2/types [get]
 func (tc *typeController) GetAll(c *gin.Context) {
-	typesMovie, err := tc.typeRepository.GetAll()
+	var query models.TypeQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		abortWithError(c, invalidRequest(err))
+		return
+	}
+	query.Normalize()
+	typesMovie, total, err := tc.typeRepository.GetAll(c.Request.Context(), &query)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, models.Response{
-			Status:  "Error",
-			Message: `Unable to get types... ` + err.Error(),
-		})
+		abortWithError(c, err)
 		return
 	}
 	if len(*typesMovie) == 0 {
 		c.JSON(http.StatusOK, models.Response{
-			Status:  "Success",
-			Message: "No types found",
+			Status:     "Success",
+			Message:    "No types found",
+			Pagination: newPagination(c, query.PageQuery, total),
 		})
 		return
 	}
 	c.JSON(http.StatusOK, models.Response{
-		Status:  "Success",
-		Message: "Types found",
-		Data:    typesMovie,
+		Status:     "Success",
+		Message:    "Types found",
+		Data:       typesMovie,
+		Pagination: newPagination(c, query.PageQuery, total),
 	})
 }
 
+// UpdateType
+// @Summary Update Type
+// @Description Update Type by ID. Name changes are recorded in the type audit.
+// @Produce application/json
+// @Param ID path string true "Update type by ID"
+// @Param tags body models.TypeRequest true "Update type"
+// @Param If-Match header string true "ETag of the version being changed, or * to overwrite any version"
+// @Tags Movie Type
+// @Success 200 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 412 {object} models.Problem
+// @Failure 428 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /types/update/{ID} [put]
+// @Router /v2/types/{ID} [put]
 func (tc *typeController) Update(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, models.Response{
-			Status:  "Error",
-			Message: "Invalid type ID",
-		})
+		abortWithError(c, utils.ErrInvalidID.Withf("Invalid type ID"))
 		return
 	}
-	var movieType *models.Type
-	if err = c.ShouldBindJSON(&movieType); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, models.Response{
-			Status:  "Error",
-			Message: `Invalid request body... ` + err.Error(),
-		})
+	var request models.TypeRequest
+	if err = c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, invalidRequest(err))
+		return
+	}
+	movieType := models.NewType(request)
+	var version uint
+	if version, err = ifMatch(c); err != nil {
+		abortWithError(c, err)
 		return
 	}
 	var movieTypeResponse *models.TypeResponse
-	if movieTypeResponse, err = tc.typeRepository.Update(uint(id), movieType); err != nil {
+	if movieTypeResponse, err = tc.typeRepository.Update(c.Request.Context(), uint(id), movieType, version); err != nil {
 		if errors.Is(err, utils.ErrNotFound) {
-			c.AbortWithStatusJSON(http.StatusNotFound, models.Response{
-				Status:  "Error",
-				Message: fmt.Sprintf("Type with ID %d not found", uint(id)),
-			})
+			abortWithError(c, utils.ErrTypeNotFound.Withf("Type with ID %d not found", uint(id)))
 		} else {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, models.Response{
-				Status:  "Error",
-				Message: `Unable to update type... ` + err.Error(),
-			})
+			abortWithError(c, err)
 		}
 		return
 	}
+	setETag(c, movieTypeResponse.Version)
 	c.JSON(http.StatusOK, models.Response{
 		Status:  "Success",
 		Message: "Type updated successfully",
@@ -165,26 +192,51 @@ func (tc *typeController) Update(c *gin.Context) {
 	})
 }
 
+// DeleteType
+// @Summary Delete Type
+// @Description Delete Type by ID. A type with movies can only be deleted when its movies are reassigned to another type.
+// @Produce application/json
+// @Param ID path string true "Delete type by ID"
+// @Param reassign_to query int false "Type ID that receives the movies of the deleted type"
+// @Param If-Match header string true "ETag of the version being changed, or * to overwrite any version"
+// @Tags Movie Type
+// @Success 200 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 412 {object} models.Problem
+// @Failure 428 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /types/delete/{ID} [delete]
+// @Router /v2/types/{ID} [delete]
 func (tc *typeController) Delete(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, models.Response{
-			Status:  "Error",
-			Message: "Invalid type ID",
-		})
+		abortWithError(c, utils.ErrInvalidID.Withf("Invalid type ID"))
 		return
 	}
-	if err = tc.typeRepository.Delete(uint(id)); err != nil {
+	var reassignTo uint64
+	if value := c.Query("reassign_to"); value != "" {
+		if reassignTo, err = strconv.ParseUint(value, 10, 64); err != nil {
+			abortWithError(c, utils.ErrInvalidID.Withf("Invalid reassignment type ID"))
+			return
+		}
+	}
+	var version uint
+	if version, err = ifMatch(c); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if err = tc.typeRepository.Delete(c.Request.Context(), uint(id), uint(reassignTo), version); err != nil {
 		if errors.Is(err, utils.ErrNotFound) {
-			c.AbortWithStatusJSON(http.StatusNotFound, models.Response{
-				Status:  "Error",
-				Message: fmt.Sprintf("Type with ID %d not found", uint(id)),
-			})
+			abortWithError(c, utils.ErrTypeNotFound.Withf("Type with ID %d not found", uint(id)))
+		} else if errors.Is(err, utils.ErrTypeNotFound) {
+			abortWithError(c, utils.ErrTypeNotFound.Withf("Reassignment type with ID %d not found", uint(reassignTo)))
+		} else if errors.Is(err, utils.ErrTypeInUse) {
+			abortWithError(c, utils.ErrTypeInUse.Withf("The type has movies, reassign them with reassign_to"))
 		} else {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, models.Response{
-				Status:  "Error",
-				Message: `Unable to delete type... ` + err.Error(),
-			})
+			abortWithError(c, err)
 		}
 		return
 	}
@@ -193,3 +245,45 @@ func (tc *typeController) Delete(c *gin.Context) {
 		Message: "Type deleted successfully",
 	})
 }
+
+// GetTypeAudits
+// @Summary Get Type audit
+// @Description Get the name changes of a type.
+// @Param ID path string true "Get type audit by ID"
+// @Produce application/json
+// @Tags Movie Type
+// @Success 200 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /types/{ID}/audits [get]
+// @Router /v2/types/{ID}/audits [get]
+func (tc *typeController) GetAudits(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		abortWithError(c, utils.ErrInvalidID.Withf("Invalid type ID"))
+		return
+	}
+	typeAudits, err := tc.typeRepository.GetAudits(c.Request.Context(), uint(id))
+	if err != nil {
+		if errors.Is(err, utils.ErrNotFound) {
+			abortWithError(c, utils.ErrTypeNotFound.Withf("Type with ID %d not found", uint(id)))
+		} else {
+			abortWithError(c, err)
+		}
+		return
+	}
+	if len(*typeAudits) == 0 {
+		c.JSON(http.StatusOK, models.Response{
+			Status:  "Success",
+			Message: "No type changes found",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, models.Response{
+		Status:  "Success",
+		Message: "Type changes found",
+		Data:    typeAudits,
+	})
+}
@@ -2,8 +2,8 @@ package controllers
 
 import (
 	"errors"
-	"fmt"
 	"github.com/gin-gonic/gin"
+	"github/jorgemvv01/go-api/middlewares"
 	"github/jorgemvv01/go-api/models"
 	"github/jorgemvv01/go-api/repositories"
 	"github/jorgemvv01/go-api/utils"
@@ -16,6 +16,7 @@ type UserController interface {
 	GetByID(c *gin.Context)
 	GetAll(c *gin.Context)
 	Update(c *gin.Context)
+	Patch(c *gin.Context)
 	Delete(c *gin.Context)
 }
 
@@ -36,23 +37,26 @@ func NewUserController(repository repositories.UserRepository) UserController {
 // @Produce application/json
 // @Tags Users
 // @Success 200 {object} models.Response{}
-// @Failure 400 {object} models.Response{}
-// @Failure 500 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
 // @Router /users/create [post]
+// @Router /v2/users [post]
 func (uc *userController) Create(c *gin.Context) {
-	var user *models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, models.Response{
-			Status:  "Error",
-			Message: `Invalid request body... ` + err.Error(),
-		})
+	var request models.UserRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, invalidRequest(err))
 		return
 	}
-	if err := uc.userRepository.Create(user); err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, models.Response{
-			Status:  "Error",
-			Message: `Unable to create user... ` + err.Error(),
-		})
+	user := models.NewUser(request)
+	if !canAssignRole(c, user.Role) {
+		abortWithError(c, utils.ErrForbidden.Withf("Only administrators can assign the %s role", user.Role))
+		return
+	}
+	if err := uc.userRepository.Create(c.Request.Context(), user); err != nil {
+		abortWithError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, models.Response{
@@ -66,35 +70,39 @@ func (uc *userController) Create(c *gin.Context) {
 // @Summary Get User by ID
 // @Description Get a user by ID.
 // @Param ID path string true "Get user by ID"
+// @Param If-None-Match header string false "ETag of the version already read, answered with 304 Not Modified when still current"
 // @Produce application/json
 // @Tags Users
 // @Success 200 {object} models.Response{}
-// @Failure 400 {object} models.Response{}
-// @Failure 404 {object} models.Response{}
-// @Failure 500 {object} models.Response{}
+// @Header 200 {string} ETag "Version of the resource"
+// @Failure 400 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
 // @Router /users/{ID} [get]
+// @Router /v2/users/{ID} [get]
 func (uc *userController) GetByID(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, models.Response{
-			Status:  "Error",
-			Message: "Invalid user ID",
-		})
+		abortWithError(c, utils.ErrInvalidID.Withf("Invalid user ID"))
 		return
 	}
-	user, err := uc.userRepository.GetByID(uint(id))
+	if !isOwnUser(c, uint(id)) {
+		abortWithError(c, utils.ErrForbidden.Withf("You can only access your own user"))
+		return
+	}
+	user, err := uc.userRepository.GetByID(c.Request.Context(), uint(id))
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, models.Response{
-			Status:  "Error",
-			Message: `Unable to get user... ` + err.Error(),
-		})
+		abortWithError(c, err)
 		return
 	}
 	if user.ID == 0 {
-		c.AbortWithStatusJSON(http.StatusNotFound, models.Response{
-			Status:  "Error",
-			Message: fmt.Sprintf("User with ID %d not found", uint(id)),
-		})
+		abortWithError(c, utils.ErrUserNotFound.Withf("User with ID %d not found", uint(id)))
+		return
+	}
+	setETag(c, user.Version)
+	if notModified(c, user.Version) {
 		return
 	}
 	c.JSON(http.StatusOK, models.Response{
@@ -107,78 +115,162 @@ func (uc *userController) GetByID(c *gin.Context) {
 // GetAllUser
 // @Summary Get all Users
 // @Description Get all Users.
+// @Param page query int false "Page number, 100000 at most"
+// @Param page_size query int false "Page size, 100 at most"
+// @Param sort query string false "Comma separated fields to sort by, prefix with - for descending order"
+// @Param surname query string false "Filter by surname"
+// @Param lastname query string false "Filter by lastname"
 // @Produce application/json
 // @Tags Users
 // @Success 200 {object} models.Response{}
-// @Failure 500 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
 // @Router /users [get]
+// @Router /v2/users [get]
 func (uc *userController) GetAll(c *gin.Context) {
-	users, err := uc.userRepository.GetAll()
+	var query models.UserQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		abortWithError(c, invalidRequest(err))
+		return
+	}
+	query.Normalize()
+	users, total, err := uc.userRepository.GetAll(c.Request.Context(), &query)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, models.Response{
-			Status:  "Error",
-			Message: `Unable to get users... ` + err.Error(),
-		})
+		abortWithError(c, err)
 		return
 	}
 	if len(*users) == 0 {
 		c.JSON(http.StatusOK, models.Response{
-			Status:  "Success",
-			Message: "No users found",
+			Status:     "Success",
+			Message:    "No users found",
+			Pagination: newPagination(c, query.PageQuery, total),
 		})
 		return
 	}
 	c.JSON(http.StatusOK, models.Response{
-		Status:  "Success",
-		Message: "Users found",
-		Data:    users,
+		Status:     "Success",
+		Message:    "Users found",
+		Data:       users,
+		Pagination: newPagination(c, query.PageQuery, total),
 	})
 }
 
 // UpdateUser
 // @Summary Update User
-// @Description Update User by ID.
+// @Description Update User by ID. Only administrators can update clerks and administrators.
 // @Produce application/json
 // @Param ID path string true "Update user by ID"
 // @Param tags body models.UserRequest true "Update user"
+// @Param If-Match header string true "ETag of the version being changed, or * to overwrite any version"
 // @Tags Users
 // @Success 200 {object} models.Response{}
-// @Failure 400 {object} models.Response{}
-// @Failure 404 {object} models.Response{}
-// @Failure 500 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 412 {object} models.Problem
+// @Failure 428 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
 // @Router /users/update/{ID} [put]
+// @Router /v2/users/{ID} [put]
 func (uc *userController) Update(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, models.Response{
-			Status:  "Error",
-			Message: "Invalid user ID",
-		})
+		abortWithError(c, utils.ErrInvalidID.Withf("Invalid user ID"))
 		return
 	}
-	var user *models.User
-	if err = c.ShouldBindJSON(&user); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, models.Response{
-			Status:  "Error",
-			Message: `Invalid request body... ` + err.Error(),
-		})
+	var request models.UserRequest
+	if err = c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, invalidRequest(err))
+		return
+	}
+	user := models.NewUser(request)
+	if !canAssignRole(c, user.Role) {
+		abortWithError(c, utils.ErrForbidden.Withf("Only administrators can assign the %s role", user.Role))
+		return
+	}
+	var version uint
+	if version, err = ifMatch(c); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if version, err = uc.checkCanModify(c, uint(id), version); err != nil {
+		abortWithError(c, err)
 		return
 	}
 	var userResponse *models.UserResponse
-	if userResponse, err = uc.userRepository.Update(uint(id), user); err != nil {
+	if userResponse, err = uc.userRepository.Update(c.Request.Context(), uint(id), user, version); err != nil {
 		if errors.Is(err, utils.ErrNotFound) {
-			c.AbortWithStatusJSON(http.StatusNotFound, models.Response{
-				Status:  "Error",
-				Message: fmt.Sprintf("User with ID %d not found", uint(id)),
-			})
+			abortWithError(c, utils.ErrUserNotFound.Withf("User with ID %d not found", uint(id)))
 		} else {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, models.Response{
-				Status:  "Error",
-				Message: `Unable to update user... ` + err.Error(),
-			})
+			abortWithError(c, err)
 		}
 		return
 	}
+	setETag(c, userResponse.Version)
+	c.JSON(http.StatusOK, models.Response{
+		Status:  "Success",
+		Message: "User updated successfully",
+		Data:    userResponse,
+	})
+}
+
+// PatchUser
+// @Summary Patch User
+// @Description Update only the given fields of a user, the body is a JSON merge patch. Only administrators can update clerks and administrators.
+// @Accept application/merge-patch+json
+// @Produce application/json
+// @Param ID path string true "Patch user by ID"
+// @Param tags body models.UserPatch true "Patch user"
+// @Param If-Match header string true "ETag of the version being changed, or * to overwrite any version"
+// @Tags Users
+// @Success 200 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 415 {object} models.Problem
+// @Failure 412 {object} models.Problem
+// @Failure 428 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
+// @Router /v2/users/{ID} [patch]
+func (uc *userController) Patch(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		abortWithError(c, utils.ErrInvalidID.Withf("Invalid user ID"))
+		return
+	}
+	var patch models.UserPatch
+	if err = bindMergePatch(c, &patch); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if patch.Role != nil && !canAssignRole(c, *patch.Role) {
+		abortWithError(c, utils.ErrForbidden.Withf("Only administrators can assign the %s role", *patch.Role))
+		return
+	}
+	var version uint
+	if version, err = ifMatch(c); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if version, err = uc.checkCanModify(c, uint(id), version); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	var userResponse *models.UserResponse
+	if userResponse, err = uc.userRepository.Patch(c.Request.Context(), uint(id), &patch, version); err != nil {
+		if errors.Is(err, utils.ErrNotFound) {
+			abortWithError(c, utils.ErrUserNotFound.Withf("User with ID %d not found", uint(id)))
+		} else {
+			abortWithError(c, err)
+		}
+		return
+	}
+	setETag(c, userResponse.Version)
 	c.JSON(http.StatusOK, models.Response{
 		Status:  "Success",
 		Message: "User updated successfully",
@@ -191,32 +283,33 @@ func (uc *userController) Update(c *gin.Context) {
 // @Description Delete User by ID.
 // @Produce application/json
 // @Param ID path string true "Delete user by ID"
+// @Param If-Match header string true "ETag of the version being changed, or * to overwrite any version"
 // @Tags Users
 // @Success 200 {object} models.Response{}
-// @Failure 400 {object} models.Response{}
-// @Failure 404 {object} models.Response{}
-// @Failure 500 {object} models.Response{}
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 412 {object} models.Problem
+// @Failure 428 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Security BearerAuth
 // @Router /users/delete/{ID} [delete]
+// @Router /v2/users/{ID} [delete]
 func (uc *userController) Delete(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, models.Response{
-			Status:  "Error",
-			Message: "Invalid user ID",
-		})
+		abortWithError(c, utils.ErrInvalidID.Withf("Invalid user ID"))
+		return
+	}
+	var version uint
+	if version, err = ifMatch(c); err != nil {
+		abortWithError(c, err)
 		return
 	}
-	if err = uc.userRepository.Delete(uint(id)); err != nil {
+	if err = uc.userRepository.Delete(c.Request.Context(), uint(id), version); err != nil {
 		if errors.Is(err, utils.ErrNotFound) {
-			c.AbortWithStatusJSON(http.StatusNotFound, models.Response{
-				Status:  "Error",
-				Message: fmt.Sprintf("User with ID %d not found", uint(id)),
-			})
+			abortWithError(c, utils.ErrUserNotFound.Withf("User with ID %d not found", uint(id)))
 		} else {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, models.Response{
-				Status:  "Error",
-				Message: `Unable to delete user...` + err.Error(),
-			})
+			abortWithError(c, err)
 		}
 		return
 	}
@@ -225,3 +318,46 @@ func (uc *userController) Delete(c *gin.Context) {
 		Message: "User deleted successfully",
 	})
 }
+
+// checkCanModify fails with ErrForbidden when the authenticated user is not an
+// administrator and the user id is a clerk or an administrator, so that staff
+// accounts cannot be taken over by clerks. It returns the version the update
+// must match: the one sent by the client or else the one checked, so that the
+// user cannot be promoted in between.
+func (uc *userController) checkCanModify(c *gin.Context, id uint, version uint) (uint, error) {
+	target, err := uc.userRepository.GetByID(c.Request.Context(), id)
+	if err != nil {
+		return 0, err
+	}
+	if target.ID == 0 {
+		return 0, utils.ErrUserNotFound.Withf("User with ID %d not found", id)
+	}
+	if _, currentRole, ok := middlewares.CurrentUser(c); ok && currentRole != models.RoleAdmin && target.Role != models.RoleCustomer {
+		return 0, utils.ErrForbidden.Withf("Only administrators can modify %s accounts", target.Role)
+	}
+	if version == 0 {
+		version = target.Version
+	}
+	return version, nil
+}
+
+// canAssignRole reports whether the authenticated user may give role to a
+// user, only administrators can create clerks and other administrators.
+// Requests that went through no authentication are trusted.
+func canAssignRole(c *gin.Context, role string) bool {
+	if role == "" || role == models.RoleCustomer {
+		return true
+	}
+	_, currentRole, ok := middlewares.CurrentUser(c)
+	return !ok || currentRole == models.RoleAdmin
+}
+
+// isOwnUser reports whether the authenticated user may access the data of the
+// user with the given ID, customers can only access their own.
+func isOwnUser(c *gin.Context, userID uint) bool {
+	if !middlewares.IsCustomer(c) {
+		return true
+	}
+	id, _, _ := middlewares.CurrentUser(c)
+	return id == userID
+}
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/go-playground/validator/v10/non-standard/validators"
+	"github/jorgemvv01/go-api/models"
+	"reflect"
+	"strings"
+	"time"
+)
+
+func init() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	// Report the invalid fields by the name clients send them with.
+	v.RegisterTagNameFunc(fieldName)
+	_ = v.RegisterValidation("notblank", validators.NotBlank)
+	_ = v.RegisterValidation("date", isDate)
+	_ = v.RegisterValidation("notpast", isNotPast)
+	_ = v.RegisterValidation("notfuture", isNotFuture)
+	_ = v.RegisterValidation("gtedatefield", isGteDateField)
+}
+
+// isDate validates that a string is a date formatted as YYYY-MM-DD.
+func isDate(fl validator.FieldLevel) bool {
+	_, err := time.Parse(models.DateLayout, fl.Field().String())
+	return err == nil
+}
+
+// isNotPast validates that a date is today or later.
+func isNotPast(fl validator.FieldLevel) bool {
+	return fl.Field().String() >= time.Now().Format(models.DateLayout)
+}
+
+// isNotFuture validates that a date is today or earlier.
+func isNotFuture(fl validator.FieldLevel) bool {
+	return fl.Field().String() <= time.Now().Format(models.DateLayout)
+}
+
+// isGteDateField validates that a date is the same as or later than the date
+// of the field whose JSON name is the parameter. Dates formatted as
+// YYYY-MM-DD sort as strings.
+func isGteDateField(fl validator.FieldLevel) bool {
+	parent := fl.Parent()
+	for parent.Kind() == reflect.Ptr {
+		parent = parent.Elem()
+	}
+	for i := 0; i < parent.NumField(); i++ {
+		if fieldName(parent.Type().Field(i)) == fl.Param() {
+			other := parent.Field(i).String()
+			if _, err := time.Parse(models.DateLayout, other); err != nil {
+				// The other date is reported on its own.
+				return true
+			}
+			return fl.Field().String() >= other
+		}
+	}
+	return false
+}
+
+func fieldMessage(fieldError validator.FieldError) string {
+	param := fieldError.Param()
+	unit := ""
+	if fieldError.Kind() == reflect.String {
+		unit = " characters long"
+	} else if fieldError.Kind() == reflect.Slice {
+		unit = " items"
+	}
+	switch fieldError.Tag() {
+	case "required", "notblank":
+		return "is required"
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(param), ", ")
+	case "min", "gte":
+		return "must be at least " + param + unit
+	case "max", "lte":
+		return "must be at most " + param + unit
+	case "gt":
+		return "must be greater than " + param
+	case "lt":
+		return "must be less than " + param
+	case "unique":
+		return "must not contain duplicates"
+	case "excludesall":
+		return "must not contain spaces"
+	case "date":
+		return "must be a date formatted as YYYY-MM-DD"
+	case "notpast":
+		return "must not be in the past"
+	case "notfuture":
+		return "must not be in the future"
+	case "gtedatefield":
+		return "must not be before " + param
+	default:
+		return "is invalid"
+	}
+}
+
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "form"} {
+		name := strings.SplitN(field.Tag.Get(tag), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"log/slog"
+	"time"
+)
+
+// SlowQueryThreshold is the duration above which a query is logged as a
+// warning.
+const SlowQueryThreshold = 200 * time.Millisecond
+
+// gormLogger writes the GORM logs through the logger of the statement
+// context, so that queries are tagged with the request that ran them.
+type gormLogger struct {
+	base *slog.Logger
+}
+
+// NewGormLogger returns a GORM logger that logs failed queries as errors,
+// slow queries as warnings and every other query at debug level. Queries run
+// outside of a request are logged with base.
+func NewGormLogger(base *slog.Logger) logger.Interface {
+	return gormLogger{base: base}
+}
+
+func (gl gormLogger) logger(ctx context.Context) *slog.Logger {
+	if log, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return log
+	}
+	return gl.base
+}
+
+func (gl gormLogger) LogMode(logger.LogLevel) logger.Interface {
+	return gl
+}
+
+func (gl gormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	gl.logger(ctx).InfoContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (gl gormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	gl.logger(ctx).WarnContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (gl gormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	gl.logger(ctx).ErrorContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (gl gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	log := gl.logger(ctx)
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := fc()
+		log.ErrorContext(ctx, "query failed", "error", err.Error(), "sql", sql, "rows", rows, "duration_ms", durationMs(elapsed))
+	case elapsed > SlowQueryThreshold:
+		sql, rows := fc()
+		log.WarnContext(ctx, "slow query", "sql", sql, "rows", rows, "duration_ms", durationMs(elapsed))
+	case log.Enabled(ctx, slog.LevelDebug):
+		sql, rows := fc()
+		log.DebugContext(ctx, "query", "sql", sql, "rows", rows, "duration_ms", durationMs(elapsed))
+	}
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github/jorgemvv01/go-api/app"
+	"github/jorgemvv01/go-api/config"
 	_ "github/jorgemvv01/go-api/docs"
-	"github/jorgemvv01/go-api/routes"
-	"github/jorgemvv01/go-api/storage"
-	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 )
 
 // @title VideoClub / Go-REST-API
@@ -15,15 +22,63 @@ import (
 // @contact.url    https://www.linkedin.com/in/jorgemariovillarreal/
 // @contact.email  jorgemvv01@gmail.com
 
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description Type "Bearer" followed by a space and the access token.
+
 // @BasePath /api
 func main() {
+	// The first argument may name a command, serving the API is the default.
+	args := os.Args[1:]
+	command := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	cfg, args, err := config.Load(args)
+	if err != nil {
+		slog.Error("unable to load the configuration", "error", err)
+		os.Exit(1)
+	}
+	gin.SetMode(cfg.HTTP.GinMode)
 
-	db := storage.GetInstance()
-	storage.MigrateModels(db)
+	a, err := app.New(cfg)
+	if err != nil {
+		slog.Error("unable to connect to the database", "error", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(a.Logger)
 
-	r := routes.SetupRoutes()
-	log.Println("[--->>>> STARTING SERVER... <<<<---]")
-	if err := r.Run(); err != nil {
-		log.Fatal("Error starting the server: ", err)
+	switch command {
+	case "serve":
+		err = serve(a)
+	case "migrate":
+		err = migrate(a, args)
+		if closeErr := a.Close(); err == nil {
+			err = closeErr
+		}
+	default:
+		err = fmt.Errorf("unknown command %q, expected serve or migrate", command)
+	}
+	if err != nil {
+		a.Logger.Error(command+" failed", "error", err)
+		os.Exit(1)
 	}
 }
+
+// serve runs the API until SIGINT or SIGTERM is received, then shuts it down
+// gracefully. A second signal terminates the process immediately.
+func serve(a *app.App) error {
+	if a.Config.Database.AutoMigrate {
+		if err := migrate(a, []string{"up"}); err != nil {
+			return err
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return a.Run(ctx)
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+	"time"
+)
+
+// catalogCollector reads the number of movies of each type from the database
+// on every scrape, so that it is right whichever instance changed the catalog.
+type catalogCollector struct {
+	db            *gorm.DB
+	moviesPerType *prometheus.Desc
+}
+
+func newCatalogCollector(db *gorm.DB) prometheus.Collector {
+	return &catalogCollector{
+		db: db,
+		moviesPerType: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "movies"),
+			"Movies in the catalog, by type.",
+			[]string{"type"}, nil,
+		),
+	}
+}
+
+func (cc *catalogCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cc.moviesPerType
+}
+
+func (cc *catalogCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var rows []struct {
+		Name  string
+		Count int64
+	}
+	err := cc.db.WithContext(ctx).Table("types").
+		Select("types.name AS name, COUNT(movies.id) AS count").
+		Joins("LEFT JOIN movies ON movies.type_id = types.id AND movies.deleted_at IS NULL").
+		Where("types.deleted_at IS NULL").
+		Group("types.name").
+		Scan(&rows).Error
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(cc.moviesPerType, err)
+		return
+	}
+	for _, row := range rows {
+		ch <- prometheus.MustNewConstMetric(cc.moviesPerType, prometheus.GaugeValue, float64(row.Count), row.Name)
+	}
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+const startKey = "metrics:start"
+
+// gormPlugin times every statement run through GORM.
+type gormPlugin struct {
+	metrics *Metrics
+}
+
+func (p *gormPlugin) Name() string {
+	return "metrics"
+}
+
+func (p *gormPlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	return firstError(
+		callbacks.Create().Before("*").Register("metrics:before_create", start),
+		callbacks.Create().After("*").Register("metrics:after_create", p.observe("create")),
+		callbacks.Query().Before("*").Register("metrics:before_query", start),
+		callbacks.Query().After("*").Register("metrics:after_query", p.observe("query")),
+		callbacks.Update().Before("*").Register("metrics:before_update", start),
+		callbacks.Update().After("*").Register("metrics:after_update", p.observe("update")),
+		callbacks.Delete().Before("*").Register("metrics:before_delete", start),
+		callbacks.Delete().After("*").Register("metrics:after_delete", p.observe("delete")),
+		callbacks.Row().Before("*").Register("metrics:before_row", start),
+		callbacks.Row().After("*").Register("metrics:after_row", p.observe("row")),
+		callbacks.Raw().Before("*").Register("metrics:before_raw", start),
+		callbacks.Raw().After("*").Register("metrics:after_raw", p.observe("raw")),
+	)
+}
+
+func start(db *gorm.DB) {
+	db.InstanceSet(startKey, time.Now())
+}
+
+func (p *gormPlugin) observe(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(startKey)
+		if !ok {
+			return
+		}
+		table := db.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+		p.metrics.queryDuration.WithLabelValues(operation, table).Observe(time.Since(value.(time.Time)).Seconds())
+	}
+}
+
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
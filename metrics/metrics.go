@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const namespace = "videoclub"
+
+// Metrics holds the collectors exposed on /metrics. Each instance has its own
+// registry, so that several applications can live in the same process.
+type Metrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	queryDuration   *prometheus.HistogramVec
+	rentsCreated    prometheus.Counter
+	rentRevenue     prometheus.Counter
+	rentsCancelled  prometheus.Counter
+	rentRefunds     prometheus.Counter
+	rentsExtended   prometheus.Counter
+	rentExtensions  prometheus.Counter
+}
+
+// New registers the HTTP, database, business and runtime metrics and
+// instruments the queries run through db.
+func New(db *gorm.DB) (*Metrics, error) {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests served, by method, route and status code.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to serve the HTTP requests, by method and route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "Time taken by the database queries, by operation and table.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"operation", "table"}),
+		rentsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rents_created_total",
+			Help:      "Rents created.",
+		}),
+		rentRevenue: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rent_revenue_total",
+			Help:      "Sum of the totals charged for the rents created.",
+		}),
+		rentsCancelled: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rents_cancelled_total",
+			Help:      "Rents cancelled.",
+		}),
+		rentRefunds: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rent_refunds_total",
+			Help:      "Sum of the refunds of the rents cancelled.",
+		}),
+		rentsExtended: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rents_extended_total",
+			Help:      "Rent extensions.",
+		}),
+		rentExtensions: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rent_extension_revenue_total",
+			Help:      "Sum of the amounts charged for the rent extensions.",
+		}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.queryDuration,
+		m.rentsCreated,
+		m.rentRevenue,
+		m.rentsCancelled,
+		m.rentRefunds,
+		m.rentsExtended,
+		m.rentExtensions,
+		newCatalogCollector(db),
+	)
+	if err := db.Use(&gormPlugin{metrics: m}); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Handler serves the metrics in the Prometheus text format. A collector
+// failing does not prevent the others from being served.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{
+		ErrorHandling: promhttp.ContinueOnError,
+	})
+}
+
+// Middleware counts the requests and observes their latency, labelled with
+// the route template rather than the path to keep the cardinality bounded.
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		m.requests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		m.requestDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// RentCreated records a new rent and the total charged for it.
+func (m *Metrics) RentCreated(total float64) {
+	m.rentsCreated.Inc()
+	m.rentRevenue.Add(total)
+}
+
+// RentCancelled records a cancelled rent and the amount refunded for it.
+func (m *Metrics) RentCancelled(refund float64) {
+	m.rentsCancelled.Inc()
+	m.rentRefunds.Add(refund)
+}
+
+// RentExtended records a rent extension and the amount charged for it.
+func (m *Metrics) RentExtended(amount float64) {
+	m.rentsExtended.Inc()
+	m.rentExtensions.Add(amount)
+}
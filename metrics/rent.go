@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"context"
+	"github/jorgemvv01/go-api/models"
+	"github/jorgemvv01/go-api/repositories"
+)
+
+// rentRepository records the rents created, cancelled and extended through
+// the wrapped repository.
+type rentRepository struct {
+	repositories.RentRepository
+	metrics *Metrics
+}
+
+// RentRepository wraps repository so that every rent it creates, cancels or
+// extends is counted along with the amount charged or refunded.
+func (m *Metrics) RentRepository(repository repositories.RentRepository) repositories.RentRepository {
+	return &rentRepository{RentRepository: repository, metrics: m}
+}
+
+func (rr *rentRepository) Create(ctx context.Context, rentRequest *models.RentRequest, days int) (*models.RentResponse, error) {
+	rent, err := rr.RentRepository.Create(ctx, rentRequest, days)
+	if err == nil {
+		rr.metrics.RentCreated(rent.Total)
+	}
+	return rent, err
+}
+
+func (rr *rentRepository) Cancel(ctx context.Context, id uint, cancelDate string) (*models.RentResponse, error) {
+	rent, err := rr.RentRepository.Cancel(ctx, id, cancelDate)
+	if err == nil {
+		rr.metrics.RentCancelled(rent.Refund)
+	}
+	return rent, err
+}
+
+func (rr *rentRepository) Extend(ctx context.Context, id uint, endDate string) (*models.RentResponse, error) {
+	rent, err := rr.RentRepository.Extend(ctx, id, endDate)
+	if err == nil {
+		rr.metrics.RentExtended(rent.Extensions[len(rent.Extensions)-1].Amount)
+	}
+	return rent, err
+}
@@ -0,0 +1,64 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github/jorgemvv01/go-api/models"
+	"github/jorgemvv01/go-api/utils"
+	"strings"
+)
+
+const userIDKey = "auth.user_id"
+const roleKey = "auth.role"
+
+// Authenticate rejects requests without a valid bearer access token and
+// stores the user ID and role of the token in the context.
+func Authenticate(tokenService utils.TokenService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		token := strings.TrimPrefix(header, "Bearer ")
+		if token == header || token == "" {
+			AbortWithError(c, utils.ErrUnauthorized.Withf("Missing bearer token"))
+			return
+		}
+		claims, err := tokenService.Parse(token, utils.AccessToken)
+		if err != nil {
+			AbortWithError(c, err)
+			return
+		}
+		c.Set(userIDKey, claims.UserID())
+		c.Set(roleKey, claims.Role)
+		c.Next()
+	}
+}
+
+// RequireRoles rejects authenticated requests whose role is not one of roles.
+// It must run after Authenticate.
+func RequireRoles(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString(roleKey)
+		for _, r := range roles {
+			if r == role {
+				c.Next()
+				return
+			}
+		}
+		AbortWithError(c, utils.ErrForbidden.Withf("You are not allowed to perform this action"))
+	}
+}
+
+// CurrentUser returns the ID and role of the authenticated user, ok is false
+// when the request went through no authentication.
+func CurrentUser(c *gin.Context) (id uint, role string, ok bool) {
+	value, ok := c.Get(userIDKey)
+	if !ok {
+		return 0, "", false
+	}
+	return value.(uint), c.GetString(roleKey), true
+}
+
+// IsCustomer reports whether the request was authenticated as a customer, who
+// may only access their own data.
+func IsCustomer(c *gin.Context) bool {
+	_, role, ok := CurrentUser(c)
+	return ok && role == models.RoleCustomer
+}
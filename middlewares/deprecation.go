@@ -0,0 +1,13 @@
+package middlewares
+
+import "github.com/gin-gonic/gin"
+
+// Deprecated flags the responses of deprecated routes with the Deprecation
+// header and links them to the successor version of the API.
+func Deprecated(successor string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Deprecation", "true")
+		c.Header("Link", "<"+successor+`>; rel="successor-version"`)
+		c.Next()
+	}
+}
@@ -0,0 +1,157 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"github/jorgemvv01/go-api/logging"
+	"github/jorgemvv01/go-api/models"
+	"github/jorgemvv01/go-api/repositories"
+	"github/jorgemvv01/go-api/utils"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// IdempotencyKeyHeader names a request so that its retries can be told apart
+// from new requests.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader flags the responses answered again to a retry.
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// Idempotent makes the retries of a request safe. The successful response to
+// a request sent with an Idempotency-Key header is stored and answered again
+// to the requests of the same user repeating the key, without handling them,
+// until the key expires. Failed requests release the key so that they can be
+// retried, panics included. Requests without the header are handled as usual.
+func Idempotent(keys repositories.IdempotencyRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > 191 {
+			AbortWithError(c, utils.ErrValidation.Withf("The %s header must be at most 191 characters long", IdempotencyKeyHeader))
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			AbortWithError(c, utils.ErrMalformedRequest)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		userID, _, _ := CurrentUser(c)
+		record, err := keys.Reserve(c.Request.Context(), userID, key, fingerprint(c, body))
+		if err != nil {
+			AbortWithError(c, err)
+			return
+		}
+		if record.Status != 0 {
+			c.Header(IdempotentReplayedHeader, "true")
+			c.Data(record.Status, record.ContentType, record.Body)
+			c.Abort()
+			return
+		}
+
+		// The response is stored even when the client gave up waiting for it,
+		// that is when it is most likely to retry.
+		ctx := context.WithoutCancel(c.Request.Context())
+		stopRenewing := renewLease(ctx, keys, record)
+		handled := false
+		defer func() {
+			stopRenewing()
+			if handled {
+				return
+			}
+			// The handler panicked, release the key before Recovery answers.
+			if err := keys.Release(ctx, record); err != nil {
+				logging.FromContext(ctx).Error("unable to release the idempotency key", "idempotency_key", key, "error", err)
+			}
+		}()
+
+		writer := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		handled = true
+		stopRenewing()
+
+		status := writer.Status()
+		if len(c.Errors) == 0 && writer.Written() && status >= http.StatusOK && status < http.StatusMultipleChoices {
+			err = keys.Complete(ctx, record, status, writer.Header().Get("Content-Type"), writer.body.Bytes())
+		} else {
+			err = keys.Release(ctx, record)
+		}
+		if err != nil {
+			logging.FromContext(ctx).Error("unable to store the idempotent response", "idempotency_key", key, "error", err)
+		}
+	}
+}
+
+// renewLease renews the lease of the reservation record halfway through, for
+// as long as its request is handled, so that a retry cannot reserve the key
+// again and handle the request twice. The returned function stops renewing
+// it and waits until any renewal in progress is done.
+func renewLease(ctx context.Context, keys repositories.IdempotencyRepository, record *models.IdempotencyRecord) func() {
+	interval := time.Until(record.ExpiresAt) / 2
+	if interval <= 0 {
+		return func() {}
+	}
+	// The record is left alone, the handler and Complete own it.
+	held := *record
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			if err := keys.Renew(ctx, &held); err != nil {
+				logging.FromContext(ctx).Error("unable to renew the idempotency key lease", "idempotency_key", held.Key, "error", err)
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+			<-stopped
+		})
+	}
+}
+
+// fingerprint identifies a request by its route and body, a key can only be
+// repeated with the same ones.
+func fingerprint(c *gin.Context, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(c.Request.Method + " " + c.FullPath() + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// recordingWriter keeps a copy of the response body written through it.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,54 @@
+package middlewares
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github/jorgemvv01/go-api/logging"
+	"github/jorgemvv01/go-api/utils"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// AccessLog logs every request once it is served. It must run after
+// RequestID.
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		ctx := c.Request.Context()
+		logging.FromContext(ctx).Log(ctx, level, "request",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"route", c.FullPath(),
+			"status", status,
+			"duration_ms", float64(time.Since(start).Microseconds())/1000,
+			"client_ip", c.ClientIP(),
+			"bytes", c.Writer.Size(),
+		)
+	}
+}
+
+// Recovery turns a panic into a 500 response and logs it with its stack.
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				ctx := c.Request.Context()
+				logging.FromContext(ctx).ErrorContext(ctx, "panic",
+					"error", fmt.Sprint(recovered),
+					"stack", string(debug.Stack()),
+				)
+				AbortWithError(c, utils.ErrInternal)
+			}
+		}()
+		c.Next()
+	}
+}
@@ -0,0 +1,18 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github/jorgemvv01/go-api/utils"
+)
+
+// RequireIfMatch rejects the requests without an If-Match header, so that
+// clients cannot overwrite changes they have not read.
+func RequireIfMatch() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("If-Match") == "" {
+			AbortWithError(c, utils.ErrPreconditionRequired.Withf("Send the ETag of the resource in If-Match"))
+			return
+		}
+		c.Next()
+	}
+}
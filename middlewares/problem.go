@@ -0,0 +1,69 @@
+package middlewares
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github/jorgemvv01/go-api/logging"
+	"github/jorgemvv01/go-api/models"
+	"github/jorgemvv01/go-api/utils"
+	"gorm.io/gorm"
+	"log/slog"
+	"net/http"
+)
+
+const ProblemContentType = "application/problem+json"
+
+// Errors answers as a problem the last error attached with c.Error by a
+// handler that did not write a response itself.
+func Errors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if len(c.Errors) > 0 && !c.Writer.Written() {
+			AbortWithError(c, c.Errors.Last().Err)
+		}
+	}
+}
+
+// AbortWithError answers err as an application/problem+json response and
+// stops the chain. Errors that are not a utils.Error are answered as internal
+// errors, their message is only logged so that database or driver details
+// never reach the clients.
+func AbortWithError(c *gin.Context, err error) {
+	appErr := toError(err)
+	ctx := c.Request.Context()
+	level := slog.LevelInfo
+	if appErr.Status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	logging.FromContext(ctx).Log(ctx, level, "request failed",
+		"code", appErr.Code,
+		"status", appErr.Status,
+		"route", c.FullPath(),
+		"error", err.Error(),
+	)
+
+	_ = c.Error(err)
+	c.Header("Content-Type", ProblemContentType)
+	c.AbortWithStatusJSON(appErr.Status, models.Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(appErr.Status),
+		Status:    appErr.Status,
+		Detail:    appErr.Message,
+		Instance:  c.Request.URL.Path,
+		Code:      appErr.Code,
+		RequestID: GetRequestID(c),
+		Errors:    appErr.Fields,
+	})
+}
+
+func toError(err error) *utils.Error {
+	var appErr *utils.Error
+	switch {
+	case errors.As(err, &appErr):
+		return appErr
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return utils.ErrNotFound
+	default:
+		return utils.ErrInternal
+	}
+}
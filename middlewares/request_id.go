@@ -0,0 +1,48 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"github/jorgemvv01/go-api/logging"
+	"log/slog"
+	"regexp"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+const requestIDKey = "request_id"
+
+// validRequestID limits the request IDs accepted from clients, so that they
+// cannot inject anything into the logs.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// RequestID keeps the X-Request-ID of the request, or generates one, echoes
+// it in the response and stores a logger tagged with it in the request
+// context.
+func RequestID(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		ctx := logging.WithLogger(c.Request.Context(), logger.With("request_id", id))
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// GetRequestID returns the ID assigned to the request by RequestID.
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
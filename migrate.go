@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"github/jorgemvv01/go-api/app"
+	"github/jorgemvv01/go-api/migrations"
+	"github/jorgemvv01/go-api/storage"
+	"strconv"
+)
+
+// migrate runs the migrate command:
+//
+//	migrate [up]      applies the pending migrations and seeds the database
+//	migrate down [n]  reverts the last n migrations, 1 by default
+//	migrate status    lists the migrations and when they were applied
+func migrate(a *app.App, args []string) error {
+	db := a.DB
+	action := "up"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	switch action {
+	case "up":
+		applied, err := migrations.Up(db)
+		for _, migration := range applied {
+			a.Logger.Info("applied migration", "version", migration.Version, "name", migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		return storage.Seed(db, a.Config.Auth)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			var err error
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of migrations to revert %q", args[1])
+			}
+		}
+		reverted, err := migrations.Down(db, steps)
+		for _, migration := range reverted {
+			a.Logger.Info("reverted migration", "version", migration.Version, "name", migration.Name)
+		}
+		return err
+	case "status":
+		statuses, err := migrations.Statuses(db)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-24s %s\n", status.Version, status.Name, appliedAt)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate action %q, expected up, down or status", action)
+	}
+}
@@ -0,0 +1,151 @@
+package migrations
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Migration is a versioned change of the database schema or data. Up and
+// Down run inside a transaction on the databases that support it.
+type Migration struct {
+	Version uint
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaMigration records an applied migration.
+type SchemaMigration struct {
+	Version   uint   `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"not null"`
+	AppliedAt time.Time
+}
+
+type Status struct {
+	Version   uint
+	Name      string
+	AppliedAt *time.Time
+}
+
+// all lists every migration, ordered by version. Applied migrations must
+// never be edited, add a new one instead.
+var all = []Migration{
+	initialSchema,
+	movieSearchIndex,
+	deduplicateTypes,
+	typePricingRules,
+	rowVersions,
+	idempotencyRecords,
+	rentStatuses,
+	rentExtensions,
+	idempotencyTokens,
+}
+
+// Up applies every pending migration in order and returns the applied ones.
+func Up(db *gorm.DB) ([]Migration, error) {
+	applied, err := appliedVersions(db, true)
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for _, migration := range all {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if err = db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: time.Now(),
+			}).Error
+		}); err != nil {
+			return done, fmt.Errorf("migration %d %s failed: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Down reverts the last steps applied migrations and returns the reverted
+// ones.
+func Down(db *gorm.DB, steps int) ([]Migration, error) {
+	applied, err := appliedVersions(db, true)
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for i := len(all) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := all[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if err = db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, migration.Version).Error
+		}); err != nil {
+			return done, fmt.Errorf("reverting migration %d %s failed: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Statuses returns every migration with the time it was applied, if it was.
+func Statuses(db *gorm.DB) ([]Status, error) {
+	applied, err := appliedVersions(db, false)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, 0, len(all))
+	for _, migration := range all {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if record, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &record.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Pending returns the migrations not applied yet.
+func Pending(db *gorm.DB) ([]Migration, error) {
+	applied, err := appliedVersions(db, false)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, migration := range all {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// appliedVersions returns the applied migrations by version. The
+// schema_migrations table is created when create is true, otherwise a
+// missing table means that nothing was applied.
+func appliedVersions(db *gorm.DB, create bool) (map[uint]SchemaMigration, error) {
+	if create {
+		if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+			return nil, fmt.Errorf("unable to create schema_migrations table: %w", err)
+		}
+	} else if !db.Migrator().HasTable(&SchemaMigration{}) {
+		return map[uint]SchemaMigration{}, nil
+	}
+	var records []SchemaMigration
+	if err := db.Find(&records).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[uint]SchemaMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
@@ -0,0 +1,126 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// The tables as they were when versioned migrations were introduced.
+// Databases created before then already have them, AutoMigrate leaves them
+// as they are.
+
+type userV1 struct {
+	gorm.Model
+	Surname      string `gorm:"not null"`
+	Lastname     string `gorm:"not null"`
+	Username     string `gorm:"uniqueIndex;size:191;default:null"`
+	PasswordHash string
+	Role         string `gorm:"not null;default:customer"`
+}
+
+func (userV1) TableName() string { return "users" }
+
+type pricingRuleV1 struct {
+	BaseDays            int     `gorm:"not null;default:0"`
+	SurchargePercentage float64 `gorm:"not null;default:0"`
+	DailyCap            float64 `gorm:"not null;default:0"`
+}
+
+type typeV1 struct {
+	gorm.Model
+	Name        string        `gorm:"not null"`
+	PricingRule pricingRuleV1 `gorm:"embedded"`
+}
+
+func (typeV1) TableName() string { return "types" }
+
+type typeAuditV1 struct {
+	gorm.Model
+	TypeID  uint   `gorm:"not null;index"`
+	Type    typeV1 `gorm:"foreignKey:TypeID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	OldName string `gorm:"not null"`
+	NewName string `gorm:"not null"`
+}
+
+func (typeAuditV1) TableName() string { return "type_audits" }
+
+type genreV1 struct {
+	gorm.Model
+	Name string `gorm:"not null"`
+}
+
+func (genreV1) TableName() string { return "genres" }
+
+type movieV1 struct {
+	gorm.Model
+	Name        string  `gorm:"not null"`
+	Overview    string  `gorm:"not null"`
+	Price       float64 `gorm:"not null"`
+	TypeID      uint
+	Type        typeV1 `gorm:"foreignKey:TypeID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	GenreID     uint
+	Genre       genreV1 `gorm:"foreignKey:GenreID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	ReleaseDate string  `gorm:"not null"`
+}
+
+func (movieV1) TableName() string { return "movies" }
+
+type copyV1 struct {
+	gorm.Model
+	MovieID uint
+	Movie   movieV1 `gorm:"foreignKey:MovieID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Barcode string  `gorm:"not null;uniqueIndex;size:191"`
+}
+
+func (copyV1) TableName() string { return "copies" }
+
+type rentV1 struct {
+	gorm.Model
+	UserID    uint
+	User      userV1  `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Total     float64 `gorm:"not null"`
+	StartDate string  `gorm:"not null"`
+	EndDate   string  `gorm:"not null"`
+}
+
+func (rentV1) TableName() string { return "rents" }
+
+type movieRentV1 struct {
+	gorm.Model
+	RentID     uint
+	Rent       rentV1 `gorm:"foreignKey:RentID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	MovieID    uint
+	Movie      movieV1 `gorm:"foreignKey:MovieID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	CopyID     uint
+	Copy       copyV1 `gorm:"foreignKey:CopyID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	ReturnDate string
+	LateFee    float64 `gorm:"not null;default:0"`
+}
+
+func (movieRentV1) TableName() string { return "movie_rents" }
+
+var initialSchema = Migration{
+	Version: 1,
+	Name:    "initial schema",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(
+			&userV1{},
+			&rentV1{},
+			&typeV1{},
+			&typeAuditV1{},
+			&genreV1{},
+			&movieV1{},
+			&copyV1{},
+			&movieRentV1{},
+		)
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(
+			&movieRentV1{},
+			&copyV1{},
+			&rentV1{},
+			&movieV1{},
+			&genreV1{},
+			&typeAuditV1{},
+			&typeV1{},
+			&userV1{},
+		)
+	},
+}
@@ -0,0 +1,24 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// The expression must stay the same as models.MovieSearchDocument for
+// PostgreSQL to use the index.
+const movieSearchDocumentV2 = "setweight(to_tsvector('english', name), 'A') || setweight(to_tsvector('english', overview), 'B')"
+
+var movieSearchIndex = Migration{
+	Version: 2,
+	Name:    "movie search index",
+	Up: func(tx *gorm.DB) error {
+		if tx.Dialector.Name() != "postgres" {
+			return nil
+		}
+		return tx.Exec("CREATE INDEX IF NOT EXISTS idx_movies_search ON movies USING GIN ((" + movieSearchDocumentV2 + "))").Error
+	},
+	Down: func(tx *gorm.DB) error {
+		if tx.Dialector.Name() != "postgres" {
+			return nil
+		}
+		return tx.Exec("DROP INDEX IF EXISTS idx_movies_search").Error
+	},
+}
@@ -0,0 +1,38 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// The movie types used to be inserted again on every start. Keep the oldest
+// type of each name, move the movies and audits of its duplicates to it and
+// delete the duplicates. The duplicates cannot be restored.
+var deduplicateTypes = Migration{
+	Version: 3,
+	Name:    "deduplicate types",
+	Up: func(tx *gorm.DB) error {
+		var types []typeV1
+		if err := tx.Order("id").Find(&types).Error; err != nil {
+			return err
+		}
+		kept := make(map[string]uint, len(types))
+		for _, t := range types {
+			keptID, ok := kept[t.Name]
+			if !ok {
+				kept[t.Name] = t.ID
+				continue
+			}
+			if err := tx.Model(&movieV1{}).Unscoped().Where("type_id = ?", t.ID).Update("type_id", keptID).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&typeAuditV1{}).Unscoped().Where("type_id = ?", t.ID).Update("type_id", keptID).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Delete(&typeV1{}, t.ID).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		return nil
+	},
+}
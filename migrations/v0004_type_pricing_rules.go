@@ -0,0 +1,29 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// Types 2 and 3 were priced by a hardcoded rule before pricing rules were
+// stored in the database, keep charging them the same way until edited.
+var typePricingRules = Migration{
+	Version: 4,
+	Name:    "type pricing rules",
+	Up: func(tx *gorm.DB) error {
+		for id, rule := range map[uint]pricingRuleV1{
+			2: {BaseDays: 3, SurchargePercentage: 15},
+			3: {BaseDays: 5, SurchargePercentage: 10},
+		} {
+			if err := tx.Model(&typeV1{}).
+				Where("id = ? AND base_days = 0 AND surcharge_percentage = 0 AND daily_cap = 0", id).
+				Updates(map[string]interface{}{
+					"base_days":            rule.BaseDays,
+					"surcharge_percentage": rule.SurchargePercentage,
+				}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		return nil
+	},
+}
@@ -0,0 +1,36 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// versionV5 is the version of a row, bumped on every update so that clients
+// can tell whether it changed since they read it.
+type versionV5 struct {
+	Version uint `gorm:"not null;default:1"`
+}
+
+var versionedTablesV5 = []string{"types", "genres", "movies", "users"}
+
+var rowVersions = Migration{
+	Version: 5,
+	Name:    "row versions",
+	Up: func(tx *gorm.DB) error {
+		for _, table := range versionedTablesV5 {
+			migrator := tx.Table(table).Migrator()
+			if migrator.HasColumn(&versionV5{}, "Version") {
+				continue
+			}
+			if err := migrator.AddColumn(&versionV5{}, "Version"); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		for _, table := range versionedTablesV5 {
+			if err := tx.Table(table).Migrator().DropColumn(&versionV5{}, "Version"); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
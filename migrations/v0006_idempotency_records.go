@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+type idempotencyRecordV6 struct {
+	ID          uint   `gorm:"primarykey"`
+	UserID      uint   `gorm:"not null;uniqueIndex:idx_idempotency_records_user_key"`
+	Key         string `gorm:"column:idempotency_key;not null;size:191;uniqueIndex:idx_idempotency_records_user_key"`
+	Fingerprint string `gorm:"not null;size:64"`
+	Status      int    `gorm:"not null;default:0"`
+	ContentType string `gorm:"not null;default:''"`
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time `gorm:"not null;index"`
+}
+
+func (idempotencyRecordV6) TableName() string { return "idempotency_records" }
+
+var idempotencyRecords = Migration{
+	Version: 6,
+	Name:    "idempotency records",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&idempotencyRecordV6{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&idempotencyRecordV6{})
+	},
+}
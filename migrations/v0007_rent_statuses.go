@@ -0,0 +1,69 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// rentStatusV7 is the status of a rent, with the refund and the date of the
+// cancelled ones.
+type rentStatusV7 struct {
+	Status     string  `gorm:"not null;size:20;default:reserved;index:idx_rents_status"`
+	Refund     float64 `gorm:"not null;default:0"`
+	CancelDate string  `gorm:"not null;default:''"`
+}
+
+var rentStatusColumnsV7 = []string{"Status", "Refund", "CancelDate"}
+
+// Rents existing before statuses are returned when every movie was returned,
+// otherwise they follow their dates.
+var rentStatuses = Migration{
+	Version: 7,
+	Name:    "rent statuses",
+	Up: func(tx *gorm.DB) error {
+		migrator := tx.Table("rents").Migrator()
+		for _, column := range rentStatusColumnsV7 {
+			if migrator.HasColumn(&rentStatusV7{}, column) {
+				continue
+			}
+			if err := migrator.AddColumn(&rentStatusV7{}, column); err != nil {
+				return err
+			}
+		}
+		if !migrator.HasIndex(&rentStatusV7{}, "idx_rents_status") {
+			if err := migrator.CreateIndex(&rentStatusV7{}, "idx_rents_status"); err != nil {
+				return err
+			}
+		}
+
+		today := time.Now().Format("2006-01-02")
+		outstanding := tx.Table("movie_rents").Select("1").
+			Where("movie_rents.rent_id = rents.id AND movie_rents.deleted_at IS NULL").
+			Where("COALESCE(movie_rents.return_date, '') = ''")
+		rents := tx.Table("rents").Where("status = ?", "reserved").Session(&gorm.Session{})
+		if err := rents.
+			Where("EXISTS (?)", tx.Table("movie_rents").Select("1").Where("movie_rents.rent_id = rents.id AND movie_rents.deleted_at IS NULL")).
+			Where("NOT EXISTS (?)", outstanding).
+			Update("status", "returned").Error; err != nil {
+			return err
+		}
+		if err := rents.Where("end_date < ?", today).Update("status", "overdue").Error; err != nil {
+			return err
+		}
+		return rents.Where("start_date <= ?", today).Update("status", "active").Error
+	},
+	Down: func(tx *gorm.DB) error {
+		migrator := tx.Table("rents").Migrator()
+		if migrator.HasIndex(&rentStatusV7{}, "idx_rents_status") {
+			if err := migrator.DropIndex(&rentStatusV7{}, "idx_rents_status"); err != nil {
+				return err
+			}
+		}
+		for _, column := range rentStatusColumnsV7 {
+			if err := migrator.DropColumn(&rentStatusV7{}, column); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
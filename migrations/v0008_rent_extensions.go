@@ -0,0 +1,25 @@
+package migrations
+
+import "gorm.io/gorm"
+
+type rentExtensionV8 struct {
+	gorm.Model
+	RentID          uint    `gorm:"not null;index"`
+	Rent            rentV1  `gorm:"foreignKey:RentID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	PreviousEndDate string  `gorm:"not null"`
+	EndDate         string  `gorm:"not null"`
+	Amount          float64 `gorm:"not null;default:0"`
+}
+
+func (rentExtensionV8) TableName() string { return "rent_extensions" }
+
+var rentExtensions = Migration{
+	Version: 8,
+	Name:    "rent extensions",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&rentExtensionV8{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&rentExtensionV8{})
+	},
+}
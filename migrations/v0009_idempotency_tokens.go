@@ -0,0 +1,24 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// idempotencyTokenV9 tells apart the reservations of a key, record IDs can be
+// reused once the last record is deleted.
+type idempotencyTokenV9 struct {
+	Token string `gorm:"not null;size:32;default:''"`
+}
+
+var idempotencyTokens = Migration{
+	Version: 9,
+	Name:    "idempotency tokens",
+	Up: func(tx *gorm.DB) error {
+		migrator := tx.Table("idempotency_records").Migrator()
+		if migrator.HasColumn(&idempotencyTokenV9{}, "Token") {
+			return nil
+		}
+		return migrator.AddColumn(&idempotencyTokenV9{}, "Token")
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Table("idempotency_records").Migrator().DropColumn(&idempotencyTokenV9{}, "Token")
+	},
+}
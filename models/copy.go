@@ -0,0 +1,36 @@
+package models
+
+import "gorm.io/gorm"
+
+type Copy struct {
+	gorm.Model
+	MovieID uint   `json:"movie_id"`
+	Movie   Movie  `gorm:"foreignKey:MovieID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Barcode string `json:"barcode" gorm:"not null;uniqueIndex;size:191"`
+}
+
+type CopyRequest struct {
+	MovieID uint   `json:"movie_id" binding:"required"`
+	Barcode string `json:"barcode" binding:"required,notblank,max=191"`
+}
+
+type CopyResponse struct {
+	ID      uint   `json:"id"`
+	MovieID uint   `json:"movie_id"`
+	Barcode string `json:"barcode"`
+}
+
+func NewCopy(request CopyRequest) *Copy {
+	return &Copy{
+		MovieID: request.MovieID,
+		Barcode: request.Barcode,
+	}
+}
+
+func NewCopyResponse(movieCopy Copy) *CopyResponse {
+	return &CopyResponse{
+		ID:      movieCopy.ID,
+		MovieID: movieCopy.MovieID,
+		Barcode: movieCopy.Barcode,
+	}
+}
@@ -4,21 +4,50 @@ import "gorm.io/gorm"
 
 type Genre struct {
 	gorm.Model
-	Name string `json:"name" binding:"required" gorm:"not null"`
+	Name    string `json:"name" gorm:"not null"`
+	Version uint   `json:"-" gorm:"not null;default:1"`
 }
 
 type GenreRequest struct {
-	Name string `json:"name"`
+	Name string `json:"name" binding:"required,notblank,max=100"`
+}
+
+// GenrePatch is a JSON merge patch of a genre, only the fields it carries
+// are updated.
+type GenrePatch struct {
+	Name *string `json:"name" binding:"omitempty,notblank,max=100"`
+}
+
+type GenreQuery struct {
+	PageQuery
+	Name string `form:"name"`
 }
 
 type GenreResponse struct {
-	ID   uint   `json:"id"`
-	Name string `json:"name"`
+	ID      uint   `json:"id"`
+	Name    string `json:"name"`
+	Version uint   `json:"-"`
+}
+
+func NewGenre(request GenreRequest) *Genre {
+	return &Genre{
+		Name: request.Name,
+	}
+}
+
+// Changes returns the columns set by the patch.
+func (patch GenrePatch) Changes() map[string]interface{} {
+	changes := make(map[string]interface{})
+	if patch.Name != nil {
+		changes["name"] = *patch.Name
+	}
+	return changes
 }
 
 func NewGenreResponse(genre Genre) *GenreResponse {
 	return &GenreResponse{
-		ID:   genre.ID,
-		Name: genre.Name,
+		ID:      genre.ID,
+		Name:    genre.Name,
+		Version: genre.Version,
 	}
 }
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// IdempotencyRecord remembers the response to a request sent with an
+// Idempotency-Key header, so that retries of the request are answered the
+// same way instead of being handled again. A record without a status is
+// still being handled, by the request that reserved it with Token.
+type IdempotencyRecord struct {
+	ID          uint   `gorm:"primarykey"`
+	UserID      uint   `gorm:"not null;uniqueIndex:idx_idempotency_records_user_key"`
+	Key         string `gorm:"column:idempotency_key;not null;size:191;uniqueIndex:idx_idempotency_records_user_key"`
+	Fingerprint string `gorm:"not null;size:64"`
+	Token       string `gorm:"not null;size:32;default:''"`
+	Status      int    `gorm:"not null;default:0"`
+	ContentType string `gorm:"not null;default:''"`
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time `gorm:"not null;index"`
+}
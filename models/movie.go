@@ -4,32 +4,69 @@ import (
 	"gorm.io/gorm"
 )
 
+// MovieSearchDocument is the PostgreSQL full-text search document of a movie,
+// matches in the name weigh more than matches in the overview.
+const MovieSearchDocument = "setweight(to_tsvector('english', name), 'A') || setweight(to_tsvector('english', overview), 'B')"
+
 type Movie struct {
 	gorm.Model
-	Name        string  `json:"name" binding:"required" gorm:"not null"`
-	Overview    string  `json:"overview" binding:"required" gorm:"not null"`
-	Price       float64 `json:"price" binding:"required" gorm:"not null"`
+	Name        string  `json:"name" gorm:"not null"`
+	Overview    string  `json:"overview" gorm:"not null"`
+	Price       float64 `json:"price" gorm:"not null"`
+	TypeID      uint    `json:"type_id"`
+	Type        Type    `gorm:"foreignKey:TypeID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	GenreID     uint    `json:"genre_id"`
+	Genre       Genre   `gorm:"foreignKey:GenreID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	ReleaseDate string  `json:"release_date" gorm:"not null"`
+	Version     uint    `json:"-" gorm:"not null;default:1"`
+}
+
+type MovieRequest struct {
+	Name        string  `json:"name" binding:"required,notblank,max=200"`
+	Overview    string  `json:"overview" binding:"required,notblank,max=2000"`
+	Price       float64 `json:"price" binding:"gt=0"`
 	TypeID      uint    `json:"type_id" binding:"required"`
-	Type        Type    `gorm:"foreignKey:TypeID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" binding:"-"`
 	GenreID     uint    `json:"genre_id" binding:"required"`
-	Genre       Genre   `gorm:"foreignKey:GenreID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" binding:"-"`
-	ReleaseDate string  `json:"release_date" binding:"required" gorm:"not null"`
+	ReleaseDate string  `json:"release_date" binding:"required,date"`
 }
 
-type MovieRequest struct {
-	Name        string  `json:"name"`
-	Overview    string  `json:"overview"`
-	Price       float64 `json:"price"`
-	TypeID      uint    `json:"type_id"`
-	GenreID     uint    `json:"genre_id"`
-	ReleaseDate string  `json:"release_date"`
+// MoviePatch is a JSON merge patch of a movie, only the fields it carries
+// are updated.
+type MoviePatch struct {
+	Name        *string  `json:"name" binding:"omitempty,notblank,max=200"`
+	Overview    *string  `json:"overview" binding:"omitempty,notblank,max=2000"`
+	Price       *float64 `json:"price" binding:"omitempty,gt=0"`
+	TypeID      *uint    `json:"type_id" binding:"omitempty,gt=0"`
+	GenreID     *uint    `json:"genre_id" binding:"omitempty,gt=0"`
+	ReleaseDate *string  `json:"release_date" binding:"omitempty,date"`
+}
+
+type MovieQuery struct {
+	PageQuery
+	GenreID     uint    `form:"genre_id"`
+	TypeID      uint    `form:"type_id"`
+	MinPrice    float64 `form:"min_price" binding:"gte=0"`
+	MaxPrice    float64 `form:"max_price" binding:"gte=0"`
+	ReleaseYear int     `form:"release_year" binding:"gte=0"`
+}
+
+type MovieSearchQuery struct {
+	PageQuery
+	Q string `form:"q" binding:"required"`
+}
+
+type MovieSearchResponse struct {
+	MovieResponse
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
 }
 
 type MovieSummary struct {
-	ID     uint    `json:"id"`
-	Name   string  `json:"name"`
-	Price  float64 `json:"price"`
-	TypeID uint    `json:"-"`
+	ID      uint        `json:"id"`
+	Name    string      `json:"name"`
+	Price   float64     `json:"price"`
+	TypeID  uint        `json:"-"`
+	Pricing PricingRule `json:"-"`
 }
 
 type MovieResponse struct {
@@ -40,6 +77,42 @@ type MovieResponse struct {
 	Type        TypeResponse  `json:"type"`
 	Genre       GenreResponse `json:"genre"`
 	ReleaseDate string        `json:"release_date"`
+	Version     uint          `json:"-"`
+}
+
+func NewMovie(request MovieRequest) *Movie {
+	return &Movie{
+		Name:        request.Name,
+		Overview:    request.Overview,
+		Price:       request.Price,
+		TypeID:      request.TypeID,
+		GenreID:     request.GenreID,
+		ReleaseDate: request.ReleaseDate,
+	}
+}
+
+// Changes returns the columns set by the patch.
+func (patch MoviePatch) Changes() map[string]interface{} {
+	changes := make(map[string]interface{})
+	if patch.Name != nil {
+		changes["name"] = *patch.Name
+	}
+	if patch.Overview != nil {
+		changes["overview"] = *patch.Overview
+	}
+	if patch.Price != nil {
+		changes["price"] = *patch.Price
+	}
+	if patch.TypeID != nil {
+		changes["type_id"] = *patch.TypeID
+	}
+	if patch.GenreID != nil {
+		changes["genre_id"] = *patch.GenreID
+	}
+	if patch.ReleaseDate != nil {
+		changes["release_date"] = *patch.ReleaseDate
+	}
+	return changes
 }
 
 func NewMovieResponse(movie Movie, movieType Type, movieGenre Genre) *MovieResponse {
@@ -51,14 +124,16 @@ func NewMovieResponse(movie Movie, movieType Type, movieGenre Genre) *MovieRespo
 		Type:        *NewTypeResponse(movieType),
 		Genre:       *NewGenreResponse(movieGenre),
 		ReleaseDate: movie.ReleaseDate,
+		Version:     movie.Version,
 	}
 }
 
 func NewMovieSummary(movie Movie) *MovieSummary {
 	return &MovieSummary{
-		ID:     movie.ID,
-		Name:   movie.Name,
-		Price:  movie.Price,
-		TypeID: movie.TypeID,
+		ID:      movie.ID,
+		Name:    movie.Name,
+		Price:   movie.Price,
+		TypeID:  movie.TypeID,
+		Pricing: movie.Type.PricingRule,
 	}
 }
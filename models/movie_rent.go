@@ -4,8 +4,30 @@ import "gorm.io/gorm"
 
 type MovieRent struct {
 	gorm.Model
-	RentID  uint
-	Rent    Rent `gorm:"foreignKey:RentID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
-	MovieID uint
-	Movie   Movie `gorm:"foreignKey:MovieID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	RentID     uint
+	Rent       Rent `gorm:"foreignKey:RentID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	MovieID    uint
+	Movie      Movie `gorm:"foreignKey:MovieID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	CopyID     uint
+	Copy       Copy    `gorm:"foreignKey:CopyID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	ReturnDate string  `json:"return_date"`
+	LateFee    float64 `json:"late_fee" gorm:"not null;default:0"`
+}
+
+type MovieRentResponse struct {
+	ID         uint         `json:"id"`
+	Movie      MovieSummary `json:"movie"`
+	Barcode    string       `json:"barcode,omitempty"`
+	ReturnDate string       `json:"return_date,omitempty"`
+	LateFee    float64      `json:"late_fee"`
+}
+
+func NewMovieRentResponse(movieRent MovieRent) *MovieRentResponse {
+	return &MovieRentResponse{
+		ID:         movieRent.ID,
+		Movie:      *NewMovieSummary(movieRent.Movie),
+		Barcode:    movieRent.Copy.Barcode,
+		ReturnDate: movieRent.ReturnDate,
+		LateFee:    movieRent.LateFee,
+	}
 }
@@ -0,0 +1,47 @@
+package models
+
+const DefaultPageSize = 20
+const MaxPageSize = 100
+
+// MaxPage bounds the page so that its offset, (page - 1) * page size, cannot
+// overflow.
+const MaxPage = 100000
+
+// PageQuery holds the page, page size and sort query parameters accepted by
+// every GetAll endpoint. Sort is a comma separated list of fields, a leading
+// "-" sorts the field in descending order.
+type PageQuery struct {
+	Page     int    `form:"page" binding:"gte=0,lte=100000"`
+	PageSize int    `form:"page_size" binding:"gte=0,lte=100"`
+	Sort     string `form:"sort"`
+}
+
+func (pq *PageQuery) Normalize() {
+	if pq.Page < 1 {
+		pq.Page = 1
+	}
+	if pq.PageSize < 1 {
+		pq.PageSize = DefaultPageSize
+	}
+	if pq.PageSize > MaxPageSize {
+		pq.PageSize = MaxPageSize
+	}
+}
+
+type Pagination struct {
+	Page       int    `json:"page"`
+	PageSize   int    `json:"page_size"`
+	Total      int64  `json:"total"`
+	TotalPages int    `json:"total_pages"`
+	Next       string `json:"next,omitempty"`
+	Prev       string `json:"prev,omitempty"`
+}
+
+func NewPagination(pageQuery PageQuery, total int64) *Pagination {
+	return &Pagination{
+		Page:       pageQuery.Page,
+		PageSize:   pageQuery.PageSize,
+		Total:      total,
+		TotalPages: int((total + int64(pageQuery.PageSize) - 1) / int64(pageQuery.PageSize)),
+	}
+}
@@ -2,28 +2,139 @@ package models
 
 import (
 	"gorm.io/gorm"
+	"time"
 )
 
+// DateLayout is the format of the dates of the API.
+const DateLayout = "2006-01-02"
+
 type Rent struct {
 	gorm.Model
-	UserID    uint    `json:"user_id" binding:"required"`
-	User      User    `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" binding:"-"`
-	Total     float64 `json:"total" binding:"required" gorm:"not null"`
-	StartDate string  `json:"start_date" binding:"required" gorm:"not null"`
-	EndDate   string  `json:"end_date" binding:"required" gorm:"not null"`
+	UserID     uint            `json:"user_id"`
+	User       User            `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Total      float64         `json:"total" gorm:"not null"`
+	StartDate  string          `json:"start_date" gorm:"not null"`
+	EndDate    string          `json:"end_date" gorm:"not null"`
+	Status     string          `json:"status" gorm:"not null;size:20;default:reserved;index"`
+	Refund     float64         `json:"refund" gorm:"not null;default:0"`
+	CancelDate string          `json:"cancel_date" gorm:"not null;default:''"`
+	MovieRents []MovieRent     `gorm:"foreignKey:RentID"`
+	Extensions []RentExtension `gorm:"foreignKey:RentID"`
+}
+
+// The statuses of a rent. A rent is reserved until its start date, active
+// until its end date and overdue afterwards, until every movie is returned or
+// the rent is cancelled.
+const (
+	RentReserved  = "reserved"
+	RentActive    = "active"
+	RentReturned  = "returned"
+	RentOverdue   = "overdue"
+	RentCancelled = "cancelled"
+)
+
+// RentStatuses lists every status of a rent.
+var RentStatuses = []string{RentReserved, RentActive, RentReturned, RentOverdue, RentCancelled}
+
+// rentTransitions lists the statuses a rent can move to from each status.
+var rentTransitions = map[string][]string{
+	RentReserved:  {RentActive, RentCancelled},
+	RentActive:    {RentOverdue, RentReturned, RentCancelled},
+	RentOverdue:   {RentReturned},
+	RentReturned:  {},
+	RentCancelled: {},
+}
+
+// CanTransition reports whether a rent can move from one status to another.
+func CanTransition(from string, to string) bool {
+	for _, status := range rentTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusOn returns the status of the rent on date. Reserved, active and
+// overdue follow the dates of the rent, returned and cancelled are final.
+func (rent *Rent) StatusOn(date string) string {
+	switch {
+	case rent.Status == RentReturned || rent.Status == RentCancelled:
+		return rent.Status
+	case date > rent.EndDate:
+		return RentOverdue
+	case date >= rent.StartDate:
+		return RentActive
+	default:
+		return RentReserved
+	}
 }
 
+// RentRequest rents each movie of MovieIDs once, from StartDate to EndDate,
+// which cannot be in the past.
 type RentRequest struct {
-	UserID    uint   `json:"user_id"`
-	MovieIDs  []int  `json:"movie_ids"`
-	StartDate string `json:"start_date"`
-	EndDate   string `json:"end_date"`
+	UserID    uint   `json:"user_id" binding:"required"`
+	MovieIDs  []int  `json:"movie_ids" binding:"required,min=1,unique,dive,gt=0"`
+	StartDate string `json:"start_date" binding:"required,date"`
+	EndDate   string `json:"end_date" binding:"required,date,notpast,gtedatefield=start_date"`
+}
+
+// Days returns the number of days charged for the rent.
+func (request *RentRequest) Days() int {
+	startDate, _ := time.Parse(DateLayout, request.StartDate)
+	endDate, _ := time.Parse(DateLayout, request.EndDate)
+	return int(endDate.Sub(startDate) / (24 * time.Hour))
+}
+
+// RentReturnRequest returns the movies of MovieIDs, or every movie not
+// returned yet, on ReturnDate, which cannot be in the future.
+type RentReturnRequest struct {
+	MovieIDs   []int  `json:"movie_ids" binding:"omitempty,unique,dive,gt=0"`
+	ReturnDate string `json:"return_date" binding:"omitempty,date,notfuture"`
+}
+
+type RentQuery struct {
+	PageQuery
+	UserID uint   `form:"user_id"`
+	Status string `form:"status" binding:"omitempty,oneof=reserved active returned overdue cancelled"`
 }
 
 type RentResponse struct {
-	UserID    uint           `json:"user_id"`
-	Total     float64        `json:"total"`
-	Movies    []MovieSummary `json:"movies"`
-	StartDate string         `json:"start_date"`
-	EndDate   string         `json:"end_date"`
+	ID         uint                    `json:"id"`
+	UserID     uint                    `json:"user_id"`
+	Total      float64                 `json:"total"`
+	Movies     []MovieSummary          `json:"movies"`
+	MovieRents []MovieRentResponse     `json:"movie_rents,omitempty"`
+	Extensions []RentExtensionResponse `json:"extensions,omitempty"`
+	StartDate  string                  `json:"start_date"`
+	EndDate    string                  `json:"end_date"`
+	Status     string                  `json:"status"`
+	Refund     float64                 `json:"refund"`
+	CancelDate string                  `json:"cancel_date,omitempty"`
+}
+
+func NewRentResponse(rent Rent) *RentResponse {
+	var movies []MovieSummary
+	var movieRents []MovieRentResponse
+	for _, movieRent := range rent.MovieRents {
+		movies = append(movies, *NewMovieSummary(movieRent.Movie))
+		movieRents = append(movieRents, *NewMovieRentResponse(movieRent))
+	}
+	var extensions []RentExtensionResponse
+	for _, extension := range rent.Extensions {
+		extensions = append(extensions, *NewRentExtensionResponse(extension))
+	}
+	return &RentResponse{
+		ID:         rent.ID,
+		UserID:     rent.UserID,
+		Total:      rent.Total,
+		Movies:     movies,
+		MovieRents: movieRents,
+		Extensions: extensions,
+		StartDate:  rent.StartDate,
+		EndDate:    rent.EndDate,
+		Status:     rent.Status,
+		Refund:     rent.Refund,
+		CancelDate: rent.CancelDate,
+	}
 }
@@ -0,0 +1,39 @@
+package models
+
+import "gorm.io/gorm"
+
+// RentExtension moves the end date of a rent from PreviousEndDate to EndDate.
+// The extra days are charged as a line item of their own, Amount, added to
+// the total of the rent.
+type RentExtension struct {
+	gorm.Model
+	RentID          uint    `json:"rent_id" gorm:"not null;index"`
+	Rent            Rent    `gorm:"foreignKey:RentID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	PreviousEndDate string  `json:"previous_end_date" gorm:"not null"`
+	EndDate         string  `json:"end_date" gorm:"not null"`
+	Amount          float64 `json:"amount" gorm:"not null;default:0"`
+}
+
+// RentExtensionRequest extends a rent until EndDate, which cannot be in the
+// past.
+type RentExtensionRequest struct {
+	EndDate string `json:"end_date" binding:"required,date,notpast"`
+}
+
+type RentExtensionResponse struct {
+	ID              uint    `json:"id"`
+	PreviousEndDate string  `json:"previous_end_date"`
+	EndDate         string  `json:"end_date"`
+	Amount          float64 `json:"amount"`
+	CreatedAt       string  `json:"created_at"`
+}
+
+func NewRentExtensionResponse(extension RentExtension) *RentExtensionResponse {
+	return &RentExtensionResponse{
+		ID:              extension.ID,
+		PreviousEndDate: extension.PreviousEndDate,
+		EndDate:         extension.EndDate,
+		Amount:          extension.Amount,
+		CreatedAt:       extension.CreatedAt.Format(DateLayout),
+	}
+}
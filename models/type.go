@@ -2,23 +2,57 @@ package models
 
 import "gorm.io/gorm"
 
+// PricingRule describes how a movie type is charged: the unit price for the
+// first BaseDays days and, from then on, the unit price increased by
+// SurchargePercentage and limited to DailyCap per day when DailyCap is set.
+type PricingRule struct {
+	BaseDays            int     `json:"base_days" gorm:"not null;default:0"`
+	SurchargePercentage float64 `json:"surcharge_percentage" gorm:"not null;default:0"`
+	DailyCap            float64 `json:"daily_cap" gorm:"not null;default:0"`
+}
+
 type Type struct {
 	gorm.Model
-	Name string `json:"name" binding:"required" gorm:"not null"`
+	Name string `json:"name" gorm:"not null"`
+	PricingRule
+	Version uint `json:"-" gorm:"not null;default:1"`
 }
 
 type TypeRequest struct {
-	Name string `json:"name"`
+	Name                string  `json:"name" binding:"required,notblank,max=100"`
+	BaseDays            int     `json:"base_days" binding:"gte=0,lte=365"`
+	SurchargePercentage float64 `json:"surcharge_percentage" binding:"gte=0,lte=1000"`
+	DailyCap            float64 `json:"daily_cap" binding:"gte=0"`
+}
+
+type TypeQuery struct {
+	PageQuery
+	Name string `form:"name"`
 }
 
 type TypeResponse struct {
 	ID   uint   `json:"id"`
 	Name string `json:"name"`
+	PricingRule
+	Version uint `json:"-"`
+}
+
+func NewType(request TypeRequest) *Type {
+	return &Type{
+		Name: request.Name,
+		PricingRule: PricingRule{
+			BaseDays:            request.BaseDays,
+			SurchargePercentage: request.SurchargePercentage,
+			DailyCap:            request.DailyCap,
+		},
+	}
 }
 
 func NewTypeResponse(typeMovie Type) *TypeResponse {
 	return &TypeResponse{
-		ID:   typeMovie.ID,
-		Name: typeMovie.Name,
+		ID:          typeMovie.ID,
+		Name:        typeMovie.Name,
+		PricingRule: typeMovie.PricingRule,
+		Version:     typeMovie.Version,
 	}
 }
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+type TypeAudit struct {
+	gorm.Model
+	TypeID  uint   `gorm:"not null;index"`
+	Type    Type   `gorm:"foreignKey:TypeID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	OldName string `gorm:"not null"`
+	NewName string `gorm:"not null"`
+}
+
+type TypeAuditResponse struct {
+	ID        uint      `json:"id"`
+	TypeID    uint      `json:"type_id"`
+	OldName   string    `json:"old_name"`
+	NewName   string    `json:"new_name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewTypeAuditResponse(typeAudit TypeAudit) *TypeAuditResponse {
+	return &TypeAuditResponse{
+		ID:        typeAudit.ID,
+		TypeID:    typeAudit.TypeID,
+		OldName:   typeAudit.OldName,
+		NewName:   typeAudit.NewName,
+		CreatedAt: typeAudit.CreatedAt,
+	}
+}
@@ -2,21 +2,80 @@ package models
 
 import "gorm.io/gorm"
 
+const RoleCustomer = "customer"
+const RoleClerk = "clerk"
+const RoleAdmin = "admin"
+
 type User struct {
 	gorm.Model
-	Surname  string `binding:"required" gorm:"not null"`
-	Lastname string `binding:"required" gorm:"not null"`
+	Surname      string `json:"surname" gorm:"not null"`
+	Lastname     string `json:"lastname" gorm:"not null"`
+	Username     string `json:"username" gorm:"uniqueIndex;size:191;default:null"`
+	Password     string `json:"-" gorm:"-"`
+	PasswordHash string `json:"-"`
+	Role         string `json:"role" gorm:"not null;default:customer"`
+	Version      uint   `json:"-" gorm:"not null;default:1"`
 }
 
+// UserRequest creates or updates a user. The password is limited to 72
+// characters, bcrypt ignores anything past them.
 type UserRequest struct {
-	Surname  string `json:"surname"`
-	Lastname string `json:"lastname"`
+	Surname  string `json:"surname" binding:"required,notblank,max=100"`
+	Lastname string `json:"lastname" binding:"required,notblank,max=100"`
+	Username string `json:"username" binding:"omitempty,min=3,max=50,excludesall= "`
+	Password string `json:"password" binding:"omitempty,min=6,max=72"`
+	Role     string `json:"role" binding:"omitempty,oneof=customer clerk admin"`
+}
+
+// UserPatch is a JSON merge patch of a user, only the fields it carries are
+// updated.
+type UserPatch struct {
+	Surname  *string `json:"surname" binding:"omitempty,notblank,max=100"`
+	Lastname *string `json:"lastname" binding:"omitempty,notblank,max=100"`
+	Username *string `json:"username" binding:"omitempty,min=3,max=50,excludesall= "`
+	Password *string `json:"password" binding:"omitempty,min=6,max=72"`
+	Role     *string `json:"role" binding:"omitempty,oneof=customer clerk admin"`
+}
+
+type UserQuery struct {
+	PageQuery
+	Surname  string `form:"surname"`
+	Lastname string `form:"lastname"`
 }
 
 type UserResponse struct {
 	ID       uint   `json:"id"`
 	Surname  string `json:"surname"`
 	Lastname string `json:"lastname"`
+	Username string `json:"username,omitempty"`
+	Role     string `json:"role"`
+	Version  uint   `json:"-"`
+}
+
+func NewUser(request UserRequest) *User {
+	return &User{
+		Surname:  request.Surname,
+		Lastname: request.Lastname,
+		Username: request.Username,
+		Password: request.Password,
+		Role:     request.Role,
+	}
+}
+
+// Changes returns the columns set by the patch. The username and the password
+// are left out, they are checked and hashed before being stored.
+func (patch UserPatch) Changes() map[string]interface{} {
+	changes := make(map[string]interface{})
+	if patch.Surname != nil {
+		changes["surname"] = *patch.Surname
+	}
+	if patch.Lastname != nil {
+		changes["lastname"] = *patch.Lastname
+	}
+	if patch.Role != nil {
+		changes["role"] = *patch.Role
+	}
+	return changes
 }
 
 func NewUserResponse(user User) *UserResponse {
@@ -24,5 +83,8 @@ func NewUserResponse(user User) *UserResponse {
 		ID:       user.ID,
 		Surname:  user.Surname,
 		Lastname: user.Lastname,
+		Username: user.Username,
+		Role:     user.Role,
+		Version:  user.Version,
 	}
 }
@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"context"
+	"github/jorgemvv01/go-api/models"
+	"github/jorgemvv01/go-api/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CopyRepository interface {
+	Create(ctx context.Context, movieCopy *models.Copy) (*models.CopyResponse, error)
+	GetByMovieID(ctx context.Context, movieID uint) (*[]models.CopyResponse, error)
+	Delete(ctx context.Context, id uint) error
+}
+
+type copyRepository struct {
+	db *gorm.DB
+}
+
+func NewCopyRepository(db *gorm.DB) CopyRepository {
+	return &copyRepository{
+		db: db,
+	}
+}
+
+func (cr *copyRepository) Create(ctx context.Context, movieCopy *models.Copy) (*models.CopyResponse, error) {
+	var movie models.Movie
+	if err := cr.db.WithContext(ctx).Find(&movie, movieCopy.MovieID).Error; err != nil {
+		return nil, err
+	}
+	if movie.ID == 0 {
+		return nil, utils.ErrMovieNotFound
+	}
+	// Deleted copies keep their barcode, a copy of the same movie deleted by
+	// mistake is restored along with its rents.
+	var existing models.Copy
+	if err := cr.db.WithContext(ctx).Unscoped().Where("barcode = ?", movieCopy.Barcode).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	if existing.ID != 0 {
+		if !existing.DeletedAt.Valid {
+			return nil, utils.ErrBarcodeAlreadyExists
+		}
+		if existing.MovieID != movieCopy.MovieID {
+			return nil, utils.ErrBarcodeAlreadyExists.Withf("Barcode %s belongs to a deleted copy of another movie", existing.Barcode)
+		}
+		if err := cr.db.WithContext(ctx).Unscoped().Model(&existing).Update("deleted_at", nil).Error; err != nil {
+			return nil, err
+		}
+		return models.NewCopyResponse(existing), nil
+	}
+	if err := cr.db.WithContext(ctx).Create(&movieCopy).Error; err != nil {
+		return nil, err
+	}
+	return models.NewCopyResponse(*movieCopy), nil
+}
+
+func (cr *copyRepository) GetByMovieID(ctx context.Context, movieID uint) (*[]models.CopyResponse, error) {
+	var movie models.Movie
+	if err := cr.db.WithContext(ctx).Find(&movie, movieID).Error; err != nil {
+		return nil, err
+	}
+	if movie.ID == 0 {
+		return nil, utils.ErrMovieNotFound
+	}
+	var copies *[]models.Copy
+	if err := cr.db.WithContext(ctx).Where("movie_id = ?", movieID).Order("id").Find(&copies).Error; err != nil {
+		return nil, err
+	}
+	var copiesResponse []models.CopyResponse
+	for _, movieCopy := range *copies {
+		copiesResponse = append(copiesResponse, *models.NewCopyResponse(movieCopy))
+	}
+	return &copiesResponse, nil
+}
+
+// Delete deletes the copy unless it was not returned yet or it is booked, by
+// a rent that was not cancelled. The copy is locked meanwhile so that no rent
+// books it in between.
+func (cr *copyRepository) Delete(ctx context.Context, id uint) error {
+	return cr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var movieCopy *models.Copy
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Find(&movieCopy, id).Error; err != nil {
+			return err
+		}
+		if movieCopy.ID == 0 {
+			return utils.ErrNotFound
+		}
+		var held int64
+		if err := tx.Model(&models.MovieRent{}).
+			Joins("JOIN rents ON rents.id = movie_rents.rent_id AND rents.deleted_at IS NULL").
+			Where("movie_rents.copy_id = ?", id).
+			Where("COALESCE(movie_rents.return_date, '') = ''").
+			Where("rents.status <> ?", models.RentCancelled).
+			Count(&held).Error; err != nil {
+			return err
+		}
+		if held > 0 {
+			return utils.ErrCopyInUse.Withf("Copy %s is held by %d rents", movieCopy.Barcode, held)
+		}
+		return tx.Delete(&movieCopy).Error
+	})
+}
@@ -1,17 +1,20 @@
 package repositories
 
 import (
+	"context"
 	"github/jorgemvv01/go-api/models"
 	"github/jorgemvv01/go-api/utils"
 	"gorm.io/gorm"
+	"strings"
 )
 
 type GenreRepository interface {
-	Create(genre *models.Genre) error
-	GetByID(id uint) (*models.GenreResponse, error)
-	GetAll() (*[]models.GenreResponse, error)
-	Update(id uint, genre *models.Genre) (*models.GenreResponse, error)
-	Delete(id uint) error
+	Create(ctx context.Context, genre *models.Genre) error
+	GetByID(ctx context.Context, id uint) (*models.GenreResponse, error)
+	GetAll(ctx context.Context, query *models.GenreQuery) (*[]models.GenreResponse, int64, error)
+	Update(ctx context.Context, id uint, genre *models.Genre, version uint) (*models.GenreResponse, error)
+	Patch(ctx context.Context, id uint, patch *models.GenrePatch, version uint) (*models.GenreResponse, error)
+	Delete(ctx context.Context, id uint, version uint) error
 }
 
 type genreRepository struct {
@@ -24,13 +27,13 @@ func NewGenreRepository(db *gorm.DB) GenreRepository {
 	}
 }
 
-func (gr *genreRepository) Create(genre *models.Genre) error {
-	return gr.db.Create(&genre).Error
+func (gr *genreRepository) Create(ctx context.Context, genre *models.Genre) error {
+	return gr.db.WithContext(ctx).Create(&genre).Error
 }
 
-func (gr *genreRepository) GetByID(id uint) (*models.GenreResponse, error) {
+func (gr *genreRepository) GetByID(ctx context.Context, id uint) (*models.GenreResponse, error) {
 	var genre *models.Genre
-	if err := gr.db.Find(&genre, id).Error; err != nil {
+	if err := gr.db.WithContext(ctx).Find(&genre, id).Error; err != nil {
 		return nil, err
 	}
 	if genre.ID == 0 {
@@ -39,40 +42,84 @@ func (gr *genreRepository) GetByID(id uint) (*models.GenreResponse, error) {
 	return models.NewGenreResponse(*genre), nil
 }
 
-func (gr *genreRepository) GetAll() (*[]models.GenreResponse, error) {
+var genreSortColumns = map[string]string{
+	"id":   "id",
+	"name": "name",
+}
+
+func (gr *genreRepository) GetAll(ctx context.Context, query *models.GenreQuery) (*[]models.GenreResponse, int64, error) {
+	orderBy, err := sortScope(query.Sort, genreSortColumns)
+	if err != nil {
+		return nil, 0, err
+	}
+	db := gr.db.WithContext(ctx).Model(&models.Genre{})
+	if query.Name != "" {
+		db = db.Where("LOWER(name) LIKE ?", "%"+strings.ToLower(query.Name)+"%")
+	}
+	db = db.Session(&gorm.Session{})
+	var total int64
+	if err = db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
 	var genres *[]models.Genre
-	if err := gr.db.Find(&genres).Error; err != nil {
-		return nil, err
+	if err = db.Scopes(orderBy, paginateScope(query.PageQuery)).Find(&genres).Error; err != nil {
+		return nil, 0, err
 	}
 	var genresResponse []models.GenreResponse
 	for _, genre := range *genres {
 		genresResponse = append(genresResponse, *models.NewGenreResponse(genre))
 	}
-	return &genresResponse, nil
+	return &genresResponse, total, nil
 }
 
-func (gr *genreRepository) Update(id uint, genre *models.Genre) (*models.GenreResponse, error) {
+func (gr *genreRepository) Update(ctx context.Context, id uint, genre *models.Genre, version uint) (*models.GenreResponse, error) {
 	var oldGenre *models.Genre
-	if err := gr.db.Find(&oldGenre, id).Error; err != nil {
+	if err := gr.db.WithContext(ctx).Find(&oldGenre, id).Error; err != nil {
 		return nil, err
 	}
 	if oldGenre.ID == 0 {
 		return nil, utils.ErrNotFound
 	}
-	oldGenre.Name = genre.Name
-	if err := gr.db.Save(&oldGenre).Error; err != nil {
+	if err := checkVersion(oldGenre.Version, version); err != nil {
+		return nil, err
+	}
+	if err := updateVersioned(gr.db.WithContext(ctx), &oldGenre, oldGenre.Version, map[string]interface{}{
+		"name": genre.Name,
+	}); err != nil {
 		return nil, err
 	}
 	return models.NewGenreResponse(*oldGenre), nil
 }
 
-func (gr *genreRepository) Delete(id uint) error {
+func (gr *genreRepository) Patch(ctx context.Context, id uint, patch *models.GenrePatch, version uint) (*models.GenreResponse, error) {
+	var genre *models.Genre
+	if err := gr.db.WithContext(ctx).Find(&genre, id).Error; err != nil {
+		return nil, err
+	}
+	if genre.ID == 0 {
+		return nil, utils.ErrNotFound
+	}
+	if err := checkVersion(genre.Version, version); err != nil {
+		return nil, err
+	}
+	if changes := patch.Changes(); len(changes) > 0 {
+		if err := updateVersioned(gr.db.WithContext(ctx), &genre, genre.Version, changes); err != nil {
+			return nil, err
+		}
+	}
+	return models.NewGenreResponse(*genre), nil
+}
+
+func (gr *genreRepository) Delete(ctx context.Context, id uint, version uint) error {
 	var genre *models.Genre
-	if err := gr.db.Find(&genre, id).Error; err != nil {
+	if err := gr.db.WithContext(ctx).Find(&genre, id).Error; err != nil {
 		return err
 	}
 	if genre.ID == 0 {
 		return utils.ErrNotFound
 	}
-	return gr.db.Delete(&genre).Error
+	if err := checkVersion(genre.Version, version); err != nil {
+		return err
+	}
+	return deleteVersioned(gr.db.WithContext(ctx), &genre, genre.Version)
 }
@@ -0,0 +1,144 @@
+package repositories
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github/jorgemvv01/go-api/models"
+	"github/jorgemvv01/go-api/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type IdempotencyRepository interface {
+	Reserve(ctx context.Context, userID uint, key string, fingerprint string) (*models.IdempotencyRecord, error)
+	Renew(ctx context.Context, record *models.IdempotencyRecord) error
+	Complete(ctx context.Context, record *models.IdempotencyRecord, status int, contentType string, body []byte) error
+	Release(ctx context.Context, record *models.IdempotencyRecord) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+type idempotencyRepository struct {
+	db    *gorm.DB
+	ttl   time.Duration
+	lease time.Duration
+}
+
+// NewIdempotencyRepository stores the responses to requests sent with an
+// Idempotency-Key header for ttl. A key is reserved for lease while its
+// request is handled, renewing it as long as the request runs, then it can be
+// reserved again, so that a request that never finished, because the process
+// died for instance, does not keep its key for ttl.
+func NewIdempotencyRepository(db *gorm.DB, ttl time.Duration, lease time.Duration) IdempotencyRepository {
+	return &idempotencyRepository{
+		db:    db,
+		ttl:   ttl,
+		lease: lease,
+	}
+}
+
+// Reserve claims the key of the user for the request with the given
+// fingerprint. A new record, without a status, is returned when the key is
+// free and the request must be handled. When the key was already used by the
+// same request, its record is returned to answer the stored response again.
+// The key fails with ErrIdempotencyKeyReused when it was used by another
+// request and with ErrIdempotencyKeyInUse while the first request is still
+// being handled.
+func (ir *idempotencyRepository) Reserve(ctx context.Context, userID uint, key string, fingerprint string) (*models.IdempotencyRecord, error) {
+	now := time.Now()
+	if err := ir.db.WithContext(ctx).
+		Where("user_id = ? AND idempotency_key = ? AND expires_at <= ?", userID, key, now).
+		Delete(&models.IdempotencyRecord{}).Error; err != nil {
+		return nil, err
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	record := &models.IdempotencyRecord{
+		UserID:      userID,
+		Key:         key,
+		Fingerprint: fingerprint,
+		Token:       hex.EncodeToString(token),
+		ExpiresAt:   now.Add(ir.lease),
+	}
+	result := ir.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return record, nil
+	}
+
+	var existing models.IdempotencyRecord
+	if err := ir.db.WithContext(ctx).
+		Where("user_id = ? AND idempotency_key = ?", userID, key).
+		First(&existing).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Released by the first request in the meantime.
+			return nil, utils.ErrIdempotencyKeyInUse
+		}
+		return nil, err
+	}
+	if existing.Fingerprint != fingerprint {
+		return nil, utils.ErrIdempotencyKeyReused
+	}
+	if existing.Status == 0 {
+		return nil, utils.ErrIdempotencyKeyInUse
+	}
+	return &existing, nil
+}
+
+// Renew extends the lease of the reservation record for another lease. It
+// fails with ErrIdempotencyLeaseLost when the reservation expired and the key
+// may have been reserved again.
+func (ir *idempotencyRepository) Renew(ctx context.Context, record *models.IdempotencyRecord) error {
+	expiresAt := time.Now().Add(ir.lease)
+	if err := ir.updateReservation(ctx, record, map[string]interface{}{"expires_at": expiresAt}); err != nil {
+		return err
+	}
+	record.ExpiresAt = expiresAt
+	return nil
+}
+
+// Complete stores the response to the request that reserved record, to be
+// replayed for ttl. It fails with ErrIdempotencyLeaseLost when the
+// reservation expired, the response of another request may be stored then.
+func (ir *idempotencyRepository) Complete(ctx context.Context, record *models.IdempotencyRecord, status int, contentType string, body []byte) error {
+	return ir.updateReservation(ctx, record, map[string]interface{}{
+		"status":       status,
+		"content_type": contentType,
+		"body":         body,
+		"expires_at":   time.Now().Add(ir.ttl),
+	})
+}
+
+// updateReservation stores changes in record as long as it is still an
+// unexpired reservation made with its token.
+func (ir *idempotencyRepository) updateReservation(ctx context.Context, record *models.IdempotencyRecord, changes map[string]interface{}) error {
+	result := ir.db.WithContext(ctx).Model(&models.IdempotencyRecord{}).
+		Where("id = ? AND token = ? AND status = 0 AND expires_at > ?", record.ID, record.Token, time.Now()).
+		Updates(changes)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrIdempotencyLeaseLost
+	}
+	return nil
+}
+
+// Release frees the key of a request that failed, so that it can be retried.
+// A key reserved again by another request is left alone.
+func (ir *idempotencyRepository) Release(ctx context.Context, record *models.IdempotencyRecord) error {
+	return ir.db.WithContext(ctx).Where("token = ? AND status = 0", record.Token).Delete(record).Error
+}
+
+// DeleteExpired deletes the records whose key expired and returns how many.
+func (ir *idempotencyRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	result := ir.db.WithContext(ctx).Where("expires_at <= ?", time.Now()).Delete(&models.IdempotencyRecord{})
+	return result.RowsAffected, result.Error
+}
@@ -1,17 +1,22 @@
 package repositories
 
 import (
+	"context"
+	"fmt"
 	"github/jorgemvv01/go-api/models"
 	"github/jorgemvv01/go-api/utils"
 	"gorm.io/gorm"
+	"strings"
 )
 
 type MovieRepository interface {
-	Create(movie *models.Movie) (*models.MovieResponse, error)
-	GetByID(id uint) (*models.MovieResponse, error)
-	GetAll() (*[]models.MovieResponse, error)
-	Update(id uint, movie *models.Movie) (*models.MovieResponse, error)
-	Delete(id uint) error
+	Create(ctx context.Context, movie *models.Movie) (*models.MovieResponse, error)
+	GetByID(ctx context.Context, id uint) (*models.MovieResponse, error)
+	GetAll(ctx context.Context, query *models.MovieQuery) (*[]models.MovieResponse, int64, error)
+	Search(ctx context.Context, query *models.MovieSearchQuery) (*[]models.MovieSearchResponse, int64, error)
+	Update(ctx context.Context, id uint, movie *models.Movie, version uint) (*models.MovieResponse, error)
+	Patch(ctx context.Context, id uint, patch *models.MoviePatch, version uint) (*models.MovieResponse, error)
+	Delete(ctx context.Context, id uint, version uint) error
 }
 
 type movieRepository struct {
@@ -24,108 +29,309 @@ func NewMovieRepository(db *gorm.DB) MovieRepository {
 	}
 }
 
-func (mr *movieRepository) Create(movie *models.Movie) (*models.MovieResponse, error) {
+func (mr *movieRepository) Create(ctx context.Context, movie *models.Movie) (*models.MovieResponse, error) {
 	var movieType models.Type
-	if err := mr.db.Find(&movieType, movie.TypeID).Error; err != nil {
+	if err := mr.db.WithContext(ctx).Find(&movieType, movie.TypeID).Error; err != nil {
 		return nil, err
 	}
 	if movieType.ID == 0 {
 		return nil, utils.ErrTypeNotFound
 	}
 	var movieGenre models.Genre
-	if err := mr.db.Find(&movieGenre, movie.GenreID).Error; err != nil {
+	if err := mr.db.WithContext(ctx).Find(&movieGenre, movie.GenreID).Error; err != nil {
 		return nil, err
 	}
 	if movieGenre.ID == 0 {
 		return nil, utils.ErrGenreNotFound
 	}
-	if err := mr.db.Create(&movie).Error; err != nil {
+	if err := mr.db.WithContext(ctx).Create(&movie).Error; err != nil {
 		return nil, err
 	}
 	return models.NewMovieResponse(*movie, movieType, movieGenre), nil
 }
 
-func (mr *movieRepository) GetByID(id uint) (*models.MovieResponse, error) {
+func (mr *movieRepository) GetByID(ctx context.Context, id uint) (*models.MovieResponse, error) {
 	var movie *models.Movie
-	if err := mr.db.Find(&movie, id).Error; err != nil {
+	if err := mr.db.WithContext(ctx).Preload("Type").Preload("Genre").Find(&movie, id).Error; err != nil {
 		return nil, err
 	}
-	var movieType models.Type
-	if err := mr.db.Find(&movieType, movie.TypeID).Error; err != nil {
-		return nil, err
-	}
-	var movieGenre models.Genre
-	if err := mr.db.Find(&movieGenre, movie.GenreID).Error; err != nil {
-		return nil, err
-	}
-	return models.NewMovieResponse(*movie, movieType, movieGenre), nil
+	return models.NewMovieResponse(*movie, movie.Type, movie.Genre), nil
+}
+
+var movieSortColumns = map[string]string{
+	"id":           "id",
+	"name":         "name",
+	"price":        "price",
+	"release_date": "release_date",
+	"created_at":   "created_at",
 }
 
-func (mr *movieRepository) GetAll() (*[]models.MovieResponse, error) {
+func (mr *movieRepository) GetAll(ctx context.Context, query *models.MovieQuery) (*[]models.MovieResponse, int64, error) {
+	orderBy, err := sortScope(query.Sort, movieSortColumns)
+	if err != nil {
+		return nil, 0, err
+	}
+	db := mr.db.WithContext(ctx).Model(&models.Movie{})
+	if query.GenreID != 0 {
+		db = db.Where("genre_id = ?", query.GenreID)
+	}
+	if query.TypeID != 0 {
+		db = db.Where("type_id = ?", query.TypeID)
+	}
+	if query.MinPrice != 0 {
+		db = db.Where("price >= ?", query.MinPrice)
+	}
+	if query.MaxPrice != 0 {
+		db = db.Where("price <= ?", query.MaxPrice)
+	}
+	if query.ReleaseYear != 0 {
+		db = db.Where("release_date LIKE ?", fmt.Sprintf("%04d-%%", query.ReleaseYear))
+	}
+	db = db.Session(&gorm.Session{})
+	var total int64
+	if err = db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
 	var movies *[]models.Movie
-	if err := mr.db.Find(&movies).Error; err != nil {
-		return nil, err
+	if err = db.Preload("Type").Preload("Genre").
+		Scopes(orderBy, paginateScope(query.PageQuery)).
+		Find(&movies).Error; err != nil {
+		return nil, 0, err
 	}
 	var moviesResponse []models.MovieResponse
 	for _, movie := range *movies {
-		var movieType models.Type
-		if err := mr.db.Find(&movieType, movie.TypeID).Error; err != nil {
-			return nil, err
+		moviesResponse = append(moviesResponse, *models.NewMovieResponse(movie, movie.Type, movie.Genre))
+	}
+	return &moviesResponse, total, nil
+}
+
+type movieSearchHit struct {
+	ID      uint
+	Rank    float64
+	Snippet string
+}
+
+// Search ranks the movies matching every word of the query. PostgreSQL uses
+// its full-text search, other databases fall back to matching the words with
+// LIKE.
+func (mr *movieRepository) Search(ctx context.Context, query *models.MovieSearchQuery) (*[]models.MovieSearchResponse, int64, error) {
+	var hits []movieSearchHit
+	var total int64
+	var err error
+	if mr.db.WithContext(ctx).Dialector.Name() == "postgres" {
+		hits, total, err = mr.searchFullText(ctx, query)
+	} else {
+		hits, total, err = mr.searchFallback(ctx, query)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var ids []uint
+	for _, hit := range hits {
+		ids = append(ids, hit.ID)
+	}
+	var movies []models.Movie
+	if len(ids) > 0 {
+		if err = mr.db.WithContext(ctx).Preload("Type").Preload("Genre").Find(&movies, ids).Error; err != nil {
+			return nil, 0, err
 		}
-		var movieGenre models.Genre
-		if err := mr.db.Find(&movieGenre, movie.GenreID).Error; err != nil {
-			return nil, err
+	}
+	var moviesByID = make(map[uint]models.Movie)
+	for _, movie := range movies {
+		moviesByID[movie.ID] = movie
+	}
+
+	var moviesResponse []models.MovieSearchResponse
+	for _, hit := range hits {
+		movie, ok := moviesByID[hit.ID]
+		if !ok {
+			continue
 		}
-		moviesResponse = append(moviesResponse, *models.NewMovieResponse(movie, movieType, movieGenre))
+		moviesResponse = append(moviesResponse, models.MovieSearchResponse{
+			MovieResponse: *models.NewMovieResponse(movie, movie.Type, movie.Genre),
+			Rank:          hit.Rank,
+			Snippet:       hit.Snippet,
+		})
 	}
-	return &moviesResponse, nil
+	return &moviesResponse, total, nil
 }
 
-func (mr *movieRepository) Update(id uint, movie *models.Movie) (*models.MovieResponse, error) {
+// escapedOverview is the overview HTML-escaped as html.EscapeString does, so
+// that the <mark> tags of ts_headline are its only markup.
+const escapedOverview = `replace(replace(replace(replace(replace(overview, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&#34;'), '''', '&#39;')`
+
+func (mr *movieRepository) searchFullText(ctx context.Context, query *models.MovieSearchQuery) ([]movieSearchHit, int64, error) {
+	const tsQuery = "plainto_tsquery('english', ?)"
+	db := mr.db.WithContext(ctx).Model(&models.Movie{}).
+		Where("("+models.MovieSearchDocument+") @@ "+tsQuery, query.Q).
+		Session(&gorm.Session{})
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var hits []movieSearchHit
+	if err := db.Select("id, "+
+		"ts_rank("+models.MovieSearchDocument+", "+tsQuery+") AS rank, "+
+		"ts_headline('english', "+escapedOverview+", "+tsQuery+", 'StartSel=<mark>, StopSel=</mark>, MaxWords=20, MinWords=10') AS snippet",
+		query.Q, query.Q).
+		Order("rank DESC").
+		Order("id").
+		Scopes(paginateScope(query.PageQuery)).
+		Scan(&hits).Error; err != nil {
+		return nil, 0, err
+	}
+	return hits, total, nil
+}
+
+// searchFallback matches every word with LIKE and ranks the movies by the
+// occurrences of the words, in the name and with less weight in the overview,
+// paginating in the database. Only the snippets of the page are built here.
+func (mr *movieRepository) searchFallback(ctx context.Context, query *models.MovieSearchQuery) ([]movieSearchHit, int64, error) {
+	terms := utils.SearchTerms(query.Q)
+	if len(terms) == 0 {
+		return nil, 0, nil
+	}
+	db := mr.db.WithContext(ctx).Model(&models.Movie{})
+	var ranks []string
+	var rankArgs []interface{}
+	for _, term := range terms {
+		db = db.Where("(LOWER(name) LIKE ? OR LOWER(overview) LIKE ?)", "%"+term+"%", "%"+term+"%")
+		ranks = append(ranks, occurrences("name")+" + 0.4 * "+occurrences("overview"))
+		rankArgs = append(rankArgs, term, term, term, term)
+	}
+	db = db.Session(&gorm.Session{})
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var rows []struct {
+		ID       uint
+		Score    float64
+		Overview string
+	}
+	// RANK is reserved in MySQL.
+	if err := db.Select("id, overview, ("+strings.Join(ranks, " + ")+") AS score", rankArgs...).
+		Order("score DESC").
+		Order("id").
+		Scopes(paginateScope(query.PageQuery)).
+		Scan(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+	var hits []movieSearchHit
+	for _, row := range rows {
+		hits = append(hits, movieSearchHit{
+			ID:      row.ID,
+			Rank:    row.Score,
+			Snippet: utils.HighlightSnippet(row.Overview, terms, 20),
+		})
+	}
+	return hits, total, nil
+}
+
+// occurrences counts the occurrences of a lowercase term, given as argument
+// twice, in column.
+func occurrences(column string) string {
+	return "(LENGTH(LOWER(" + column + ")) - LENGTH(REPLACE(LOWER(" + column + "), ?, ''))) / LENGTH(?)"
+}
+
+func (mr *movieRepository) Update(ctx context.Context, id uint, movie *models.Movie, version uint) (*models.MovieResponse, error) {
 	var oldMovie *models.Movie
-	if err := mr.db.Find(&oldMovie, id).Error; err != nil {
+	if err := mr.db.WithContext(ctx).Find(&oldMovie, id).Error; err != nil {
 		return nil, err
 	}
 	if oldMovie.ID == 0 {
 		return nil, utils.ErrNotFound
 	}
+	if err := checkVersion(oldMovie.Version, version); err != nil {
+		return nil, err
+	}
 
 	var movieType models.Type
-	if err := mr.db.Find(&movieType, movie.TypeID).Error; err != nil {
+	if err := mr.db.WithContext(ctx).Find(&movieType, movie.TypeID).Error; err != nil {
 		return nil, err
 	}
 	if movieType.ID == 0 {
 		return nil, utils.ErrTypeNotFound
 	}
 	var movieGenre models.Genre
-	if err := mr.db.Find(&movieGenre, movie.GenreID).Error; err != nil {
+	if err := mr.db.WithContext(ctx).Find(&movieGenre, movie.GenreID).Error; err != nil {
 		return nil, err
 	}
 	if movieGenre.ID == 0 {
 		return nil, utils.ErrGenreNotFound
 	}
 
-	oldMovie.Name = movie.Name
-	oldMovie.Overview = movie.Overview
-	oldMovie.Price = movie.Price
-	oldMovie.TypeID = movie.TypeID
-	oldMovie.GenreID = movie.GenreID
-	oldMovie.ReleaseDate = movie.ReleaseDate
-
-	if err := mr.db.Save(&oldMovie).Error; err != nil {
+	if err := updateVersioned(mr.db.WithContext(ctx), &oldMovie, oldMovie.Version, map[string]interface{}{
+		"name":         movie.Name,
+		"overview":     movie.Overview,
+		"price":        movie.Price,
+		"type_id":      movie.TypeID,
+		"genre_id":     movie.GenreID,
+		"release_date": movie.ReleaseDate,
+	}); err != nil {
 		return nil, err
 	}
 
 	return models.NewMovieResponse(*oldMovie, movieType, movieGenre), nil
 }
 
-func (mr *movieRepository) Delete(id uint) error {
+// Patch updates only the columns set by patch, so concurrent patches of
+// different fields do not overwrite each other.
+func (mr *movieRepository) Patch(ctx context.Context, id uint, patch *models.MoviePatch, version uint) (*models.MovieResponse, error) {
 	var movie *models.Movie
-	if err := mr.db.Find(&movie, id).Error; err != nil {
+	err := mr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Find(&movie, id).Error; err != nil {
+			return err
+		}
+		if movie.ID == 0 {
+			return utils.ErrNotFound
+		}
+		if err := checkVersion(movie.Version, version); err != nil {
+			return err
+		}
+		if patch.TypeID != nil {
+			var movieType models.Type
+			if err := tx.Find(&movieType, *patch.TypeID).Error; err != nil {
+				return err
+			}
+			if movieType.ID == 0 {
+				return utils.ErrTypeNotFound
+			}
+		}
+		if patch.GenreID != nil {
+			var movieGenre models.Genre
+			if err := tx.Find(&movieGenre, *patch.GenreID).Error; err != nil {
+				return err
+			}
+			if movieGenre.ID == 0 {
+				return utils.ErrGenreNotFound
+			}
+		}
+		if changes := patch.Changes(); len(changes) > 0 {
+			if err := updateVersioned(tx, &movie, movie.Version, changes); err != nil {
+				return err
+			}
+		}
+		return tx.Preload("Type").Preload("Genre").Find(&movie, id).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return models.NewMovieResponse(*movie, movie.Type, movie.Genre), nil
+}
+
+func (mr *movieRepository) Delete(ctx context.Context, id uint, version uint) error {
+	var movie *models.Movie
+	if err := mr.db.WithContext(ctx).Find(&movie, id).Error; err != nil {
 		return err
 	}
 	if movie.ID == 0 {
 		return utils.ErrNotFound
 	}
-	return mr.db.Delete(&movie).Error
+	if err := checkVersion(movie.Version, version); err != nil {
+		return err
+	}
+	return deleteVersioned(mr.db.WithContext(ctx), &movie, movie.Version)
 }
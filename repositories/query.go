@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"github/jorgemvv01/go-api/models"
+	"github/jorgemvv01/go-api/utils"
+	"gorm.io/gorm"
+	"strings"
+)
+
+// sortScope orders the query by the fields of sort. Only the fields present
+// in columns can be used, mapped to their column name. Without fields the
+// query is ordered by ID.
+func sortScope(sort string, columns map[string]string) (func(db *gorm.DB) *gorm.DB, error) {
+	var orders []string
+	for _, field := range strings.Split(sort, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		var direction = "ASC"
+		if strings.HasPrefix(field, "-") {
+			direction = "DESC"
+			field = field[1:]
+		}
+		column, ok := columns[field]
+		if !ok {
+			return nil, utils.ErrInvalidSort.Withf("Invalid sort field %q", field)
+		}
+		orders = append(orders, column+" "+direction)
+	}
+	orders = append(orders, "id ASC")
+	return func(db *gorm.DB) *gorm.DB {
+		for _, order := range orders {
+			db = db.Order(order)
+		}
+		return db
+	}, nil
+}
+
+// paginateScope selects the page of pageQuery. Pages after models.MaxPage are
+// past the end, their offset would overflow.
+func paginateScope(pageQuery models.PageQuery) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		var page = min(pageQuery.Page, models.MaxPage+1)
+		return db.Offset((page - 1) * pageQuery.PageSize).Limit(pageQuery.PageSize)
+	}
+}
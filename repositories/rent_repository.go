@@ -1,13 +1,23 @@
 package repositories
 
 import (
+	"context"
 	"github/jorgemvv01/go-api/models"
 	"github/jorgemvv01/go-api/utils"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
 )
 
 type RentRepository interface {
-	Create(rentRequest *models.RentRequest, days int) (*models.RentResponse, error)
+	Create(ctx context.Context, rentRequest *models.RentRequest, days int) (*models.RentResponse, error)
+	GetByID(ctx context.Context, id uint) (*models.RentResponse, error)
+	GetAll(ctx context.Context, query *models.RentQuery) (*[]models.RentResponse, int64, error)
+	GetByUserID(ctx context.Context, userID uint, pageQuery *models.PageQuery) (*[]models.RentResponse, int64, error)
+	Return(ctx context.Context, id uint, rentReturn *models.RentReturnRequest) (*models.RentResponse, error)
+	Cancel(ctx context.Context, id uint, cancelDate string) (*models.RentResponse, error)
+	Extend(ctx context.Context, id uint, endDate string) (*models.RentResponse, error)
+	AdvanceStatuses(ctx context.Context, date string) (int64, error)
 }
 
 type rentRepository struct {
@@ -20,28 +30,25 @@ func NewRentRepository(db *gorm.DB) RentRepository {
 	}
 }
 
-func (rr *rentRepository) Create(rentRequest *models.RentRequest, days int) (*models.RentResponse, error) {
+func (rr *rentRepository) Create(ctx context.Context, rentRequest *models.RentRequest, days int) (*models.RentResponse, error) {
 	var user *models.User
-	if err := rr.db.Find(&user, rentRequest.UserID).Error; err != nil {
+	if err := rr.db.WithContext(ctx).Find(&user, rentRequest.UserID).Error; err != nil {
 		return nil, err
 	}
 	if user.ID == 0 {
 		return nil, utils.ErrUserNotFound
 	}
-	var movies []models.MovieSummary
-	for _, movieID := range rentRequest.MovieIDs {
-		var movie *models.Movie
-		if err := rr.db.Find(&movie, movieID).Error; err != nil {
-			return nil, err
-		}
-		if movie.ID == 0 {
-			return nil, utils.ErrMovieNotFound
-		}
-		movies = append(movies, *models.NewMovieSummary(*movie))
+	movies, err := rr.findMovies(ctx, rentRequest.MovieIDs)
+	if err != nil {
+		return nil, err
 	}
-	var total = utils.CalculateTotalRent(movies, days)
+	var moviesSummary []models.MovieSummary
+	for _, movie := range movies {
+		moviesSummary = append(moviesSummary, *models.NewMovieSummary(movie))
+	}
+	var total = utils.CalculateTotalRent(moviesSummary, days)
 
-	tx := rr.db.Begin()
+	tx := rr.db.WithContext(ctx).Begin()
 
 	var rent = models.Rent{
 		UserID:    rentRequest.UserID,
@@ -49,32 +56,526 @@ func (rr *rentRepository) Create(rentRequest *models.RentRequest, days int) (*mo
 		StartDate: rentRequest.StartDate,
 		EndDate:   rentRequest.EndDate,
 	}
+	rent.Status = rent.StatusOn(today())
 
-	if err := tx.Create(&rent).Error; err != nil {
+	if err = tx.Create(&rent).Error; err != nil {
 		tx.Rollback()
 		return nil, err
 	}
 
+	if len(movies) > 0 {
+		copies, err := rr.findAvailableCopies(tx, movies, rent.StartDate, rent.EndDate)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		var movieRents []models.MovieRent
+		for i, movie := range movies {
+			movieRents = append(movieRents, models.MovieRent{
+				MovieID: movie.ID,
+				RentID:  rent.ID,
+				CopyID:  copies[i].ID,
+			})
+		}
+		if err = tx.Omit("Rent", "Movie", "Copy").Create(&movieRents).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		for i := range movieRents {
+			movieRents[i].Movie = movies[i]
+			movieRents[i].Copy = copies[i]
+		}
+		rent.MovieRents = movieRents
+	}
+
+	if err = tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return newRentResponse(rent), nil
+}
+
+// findMovies loads the movies with their type in a single query and returns
+// them in the order of movieIDs, repeating the movies requested twice.
+func (rr *rentRepository) findMovies(ctx context.Context, movieIDs []int) ([]models.Movie, error) {
+	if len(movieIDs) == 0 {
+		return nil, nil
+	}
+	var found []models.Movie
+	if err := rr.db.WithContext(ctx).Preload("Type").Find(&found, movieIDs).Error; err != nil {
+		return nil, err
+	}
+	var moviesByID = make(map[uint]models.Movie)
+	for _, movie := range found {
+		moviesByID[movie.ID] = movie
+	}
+	var movies []models.Movie
+	for _, movieID := range movieIDs {
+		movie, ok := moviesByID[uint(movieID)]
+		if !ok {
+			return nil, utils.ErrMovieNotFound
+		}
+		movies = append(movies, movie)
+	}
+	return movies, nil
+}
+
+// heldUntil is the last day a copy is held by its rent: the return date of
+// the movie, the cancel date of the rent or else the end date of the rent.
+const heldUntil = "COALESCE(NULLIF(movie_rents.return_date, ''), NULLIF(rents.cancel_date, ''), rents.end_date)"
+
+// findAvailableCopies returns a different free copy for each movie, in the
+// same order. A copy is not free when it is held by another rent between
+// startDate and endDate: returned movies are held until their return date,
+// the movies of cancelled rents until the cancel date and the rest until the
+// end date of their rent. Rents cancelled before they started hold nothing.
+func (rr *rentRepository) findAvailableCopies(tx *gorm.DB, movies []models.Movie, startDate string, endDate string) ([]models.Copy, error) {
+	var movieIDs []uint
+	for _, movie := range movies {
+		movieIDs = append(movieIDs, movie.ID)
+	}
+	if err := lockCopies(tx, "movie_id IN ?", movieIDs); err != nil {
+		return nil, err
+	}
+	busyCopies := heldCopies(tx, startDate, endDate)
+	var freeCopies []models.Copy
+	if err := tx.Where("movie_id IN ?", movieIDs).
+		Where("id NOT IN (?)", busyCopies).
+		Order("id").
+		Find(&freeCopies).Error; err != nil {
+		return nil, err
+	}
+	var copiesByMovie = make(map[uint][]models.Copy)
+	for _, movieCopy := range freeCopies {
+		copiesByMovie[movieCopy.MovieID] = append(copiesByMovie[movieCopy.MovieID], movieCopy)
+	}
+
+	var copies []models.Copy
 	for _, movie := range movies {
-		var movieRent = models.MovieRent{
-			MovieID: movie.ID,
-			RentID:  rent.ID,
+		if len(copiesByMovie[movie.ID]) == 0 {
+			return nil, utils.ErrMovieUnavailable
+		}
+		copies = append(copies, copiesByMovie[movie.ID][0])
+		copiesByMovie[movie.ID] = copiesByMovie[movie.ID][1:]
+	}
+	return copies, nil
+}
+
+// lockCopies locks the copies matching query until the end of tx, so that the
+// rents booking them check whether they are free one after the other instead
+// of booking the same copy at once. The copies are locked in order to avoid
+// deadlocks. SQLite does not lock rows, it runs one writing transaction at a
+// time instead.
+func lockCopies(tx *gorm.DB, query interface{}, args ...interface{}) error {
+	var ids []uint
+	return tx.Model(&models.Copy{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where(query, args...).
+		Order("id").
+		Pluck("id", &ids).Error
+}
+
+// heldCopies selects the copies held by a rent between startDate and endDate.
+func heldCopies(tx *gorm.DB, startDate string, endDate string) *gorm.DB {
+	return tx.Model(&models.MovieRent{}).
+		Select("movie_rents.copy_id").
+		Joins("JOIN rents ON rents.id = movie_rents.rent_id AND rents.deleted_at IS NULL").
+		Where("movie_rents.copy_id IS NOT NULL").
+		Where("rents.start_date <= ?", endDate).
+		Where(heldUntil+" >= ?", startDate).
+		Where(heldUntil + " >= rents.start_date")
+}
+
+func (rr *rentRepository) GetByID(ctx context.Context, id uint) (*models.RentResponse, error) {
+	var rent *models.Rent
+	if err := rr.db.WithContext(ctx).Preload("MovieRents.Movie").Preload("MovieRents.Copy").Preload("Extensions").Find(&rent, id).Error; err != nil {
+		return nil, err
+	}
+	if rent.ID == 0 {
+		return nil, utils.ErrRentNotFound
+	}
+	return newRentResponse(*rent), nil
+}
+
+var rentSortColumns = map[string]string{
+	"id":         "id",
+	"total":      "total",
+	"start_date": "start_date",
+	"end_date":   "end_date",
+}
+
+func (rr *rentRepository) GetAll(ctx context.Context, query *models.RentQuery) (*[]models.RentResponse, int64, error) {
+	orderBy, err := sortScope(query.Sort, rentSortColumns)
+	if err != nil {
+		return nil, 0, err
+	}
+	db := rr.db.WithContext(ctx).Model(&models.Rent{})
+	if query.UserID != 0 {
+		db = db.Where("user_id = ?", query.UserID)
+	}
+	if query.Status != "" {
+		db = db.Scopes(rentStatusScope(query.Status, today()))
+	}
+	db = db.Session(&gorm.Session{})
+	var total int64
+	if err = db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var rents *[]models.Rent
+	if err = db.Preload("MovieRents.Movie").Preload("MovieRents.Copy").Preload("Extensions").
+		Scopes(orderBy, paginateScope(query.PageQuery)).
+		Find(&rents).Error; err != nil {
+		return nil, 0, err
+	}
+	var rentsResponse []models.RentResponse
+	for _, rent := range *rents {
+		rentsResponse = append(rentsResponse, *newRentResponse(rent))
+	}
+	return &rentsResponse, total, nil
+}
+
+func (rr *rentRepository) GetByUserID(ctx context.Context, userID uint, pageQuery *models.PageQuery) (*[]models.RentResponse, int64, error) {
+	var user *models.User
+	if err := rr.db.WithContext(ctx).Find(&user, userID).Error; err != nil {
+		return nil, 0, err
+	}
+	if user.ID == 0 {
+		return nil, 0, utils.ErrUserNotFound
+	}
+	return rr.GetAll(ctx, &models.RentQuery{PageQuery: *pageQuery, UserID: userID})
+}
+
+func (rr *rentRepository) Return(ctx context.Context, id uint, rentReturn *models.RentReturnRequest) (*models.RentResponse, error) {
+	var rent *models.Rent
+	if err := rr.db.WithContext(ctx).Preload("MovieRents.Movie.Type").Preload("MovieRents.Copy").Preload("Extensions").Find(&rent, id).Error; err != nil {
+		return nil, err
+	}
+	if rent.ID == 0 {
+		return nil, utils.ErrRentNotFound
+	}
+
+	startDate, err := time.Parse(models.DateLayout, rent.StartDate)
+	if err != nil {
+		return nil, err
+	}
+	endDate, err := time.Parse(models.DateLayout, rent.EndDate)
+	if err != nil {
+		return nil, err
+	}
+	returnDate, err := time.Parse(models.DateLayout, rentReturn.ReturnDate)
+	if err != nil {
+		return nil, err
+	}
+	var status = rent.StatusOn(rentReturn.ReturnDate)
+	if status == models.RentCancelled {
+		return nil, invalidRentTransition(status, models.RentReturned)
+	}
+	if returnDate.Before(startDate) {
+		return nil, utils.ErrInvalidReturnDate
+	}
+	for _, movieRent := range rent.MovieRents {
+		if movieRent.ReturnDate > rentReturn.ReturnDate {
+			return nil, utils.ErrInvalidReturnDate.Withf("The return date must not be before %s, the last return of the rent", movieRent.ReturnDate)
+		}
+	}
+	var rentDays = int(endDate.Sub(startDate) / (24 * time.Hour))
+	var lateDays = int(returnDate.Sub(endDate) / (24 * time.Hour))
+
+	var returned = make(map[uint]bool)
+	if len(rentReturn.MovieIDs) == 0 {
+		for _, movieRent := range rent.MovieRents {
+			if movieRent.ReturnDate == "" {
+				returned[movieRent.ID] = true
+			}
+		}
+		if len(returned) == 0 {
+			return nil, utils.ErrMovieAlreadyReturned
+		}
+	}
+	for _, movieID := range rentReturn.MovieIDs {
+		var found bool
+		for _, movieRent := range rent.MovieRents {
+			if movieRent.MovieID != uint(movieID) {
+				continue
+			}
+			found = true
+			if movieRent.ReturnDate != "" {
+				return nil, utils.ErrMovieAlreadyReturned
+			}
+			returned[movieRent.ID] = true
+		}
+		if !found {
+			return nil, utils.ErrMovieNotInRent
 		}
-		if err := tx.Create(&movieRent).Error; err != nil {
+	}
+
+	tx := rr.db.WithContext(ctx).Begin()
+
+	// Movies returned by a concurrent request are left untouched and the fees
+	// are added to the stored total, so that returns do not overwrite each
+	// other.
+	var fees float64
+	for i := range rent.MovieRents {
+		var movieRent = &rent.MovieRents[i]
+		if !returned[movieRent.ID] {
+			continue
+		}
+		movieRent.ReturnDate = rentReturn.ReturnDate
+		movieRent.LateFee = utils.CalculateLateFee(*models.NewMovieSummary(movieRent.Movie), rentDays, lateDays)
+		fees += movieRent.LateFee
+		result := tx.Model(&models.MovieRent{}).
+			Where("id = ? AND COALESCE(return_date, '') = ''", movieRent.ID).
+			Updates(map[string]interface{}{
+				"return_date": movieRent.ReturnDate,
+				"late_fee":    movieRent.LateFee,
+			})
+		if result.Error != nil {
+			tx.Rollback()
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			tx.Rollback()
+			return nil, utils.ErrMovieAlreadyReturned
+		}
+	}
+
+	// Until every movie is returned the status is left to the passing of time.
+	var outstanding int64
+	if err = tx.Model(&models.MovieRent{}).
+		Where("rent_id = ? AND COALESCE(return_date, '') = ''", rent.ID).
+		Count(&outstanding).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	status = rent.Status
+	if outstanding == 0 {
+		status = models.RentReturned
+	}
+	// The late fees are priced against the end date, which an extension may
+	// have moved since the rent was loaded.
+	result := tx.Model(&models.Rent{}).
+		Where("id = ? AND status = ? AND end_date = ?", rent.ID, rent.Status, rent.EndDate).
+		Updates(map[string]interface{}{
+			"status": status,
+			"total":  gorm.Expr("total + ?", fees),
+		})
+	if result.Error != nil {
+		tx.Rollback()
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return nil, utils.ErrInvalidRentTransition.Withf("The rent changed while it was being returned")
+	}
+	if err = tx.Model(&models.Rent{}).Select("total").Where("id = ?", rent.ID).Scan(&rent.Total).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	rent.Status = status
+
+	if err = tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return newRentResponse(*rent), nil
+}
+
+// Cancel cancels the rent on cancelDate, refunding its total when it had not
+// started yet. The copies of the rent are free again from cancelDate.
+func (rr *rentRepository) Cancel(ctx context.Context, id uint, cancelDate string) (*models.RentResponse, error) {
+	var rent *models.Rent
+	if err := rr.db.WithContext(ctx).Preload("MovieRents.Movie").Preload("MovieRents.Copy").Preload("Extensions").Find(&rent, id).Error; err != nil {
+		return nil, err
+	}
+	if rent.ID == 0 {
+		return nil, utils.ErrRentNotFound
+	}
+
+	var status = rent.StatusOn(cancelDate)
+	if !models.CanTransition(status, models.RentCancelled) {
+		return nil, invalidRentTransition(status, models.RentCancelled)
+	}
+	var refund float64
+	if status == models.RentReserved {
+		refund = rent.Total
+	}
+	if err := rr.updateStatus(rr.db.WithContext(ctx), rent, models.RentCancelled, map[string]interface{}{
+		"refund":      refund,
+		"cancel_date": cancelDate,
+	}); err != nil {
+		return nil, err
+	}
+	rent.Refund = refund
+	rent.CancelDate = cancelDate
+
+	return newRentResponse(*rent), nil
+}
+
+// Extend moves the end date of the rent to endDate, keeping the copies of the
+// movies not returned yet, as long as no other rent holds them for the extra
+// days. The extra days are priced as if the movies had been rented until
+// endDate from the start and recorded as an extension, the original charge is
+// left untouched.
+func (rr *rentRepository) Extend(ctx context.Context, id uint, endDate string) (*models.RentResponse, error) {
+	var rent *models.Rent
+	if err := rr.db.WithContext(ctx).Preload("MovieRents.Movie.Type").Preload("MovieRents.Copy").Preload("Extensions").Find(&rent, id).Error; err != nil {
+		return nil, err
+	}
+	if rent.ID == 0 {
+		return nil, utils.ErrRentNotFound
+	}
+	if status := rent.StatusOn(today()); status != models.RentReserved && status != models.RentActive {
+		return nil, utils.ErrRentNotExtendable.Withf("The rent is %s and cannot be extended", status)
+	}
+	if endDate <= rent.EndDate {
+		return nil, utils.ErrInvalidExtensionDate.Withf("The end date must be after %s", rent.EndDate)
+	}
+
+	startDate, err := time.Parse(models.DateLayout, rent.StartDate)
+	if err != nil {
+		return nil, err
+	}
+	previousEndDate, err := time.Parse(models.DateLayout, rent.EndDate)
+	if err != nil {
+		return nil, err
+	}
+	newEndDate, err := time.Parse(models.DateLayout, endDate)
+	if err != nil {
+		return nil, err
+	}
+	var rentDays = int(previousEndDate.Sub(startDate) / (24 * time.Hour))
+	var extendedDays = int(newEndDate.Sub(startDate) / (24 * time.Hour))
+
+	var movies []models.MovieSummary
+	var copyIDs []uint
+	var barcodes = make(map[uint]string)
+	for _, movieRent := range rent.MovieRents {
+		if movieRent.ReturnDate != "" {
+			continue
+		}
+		movies = append(movies, *models.NewMovieSummary(movieRent.Movie))
+		if movieRent.CopyID != 0 {
+			copyIDs = append(copyIDs, movieRent.CopyID)
+			barcodes[movieRent.CopyID] = movieRent.Copy.Barcode
+		}
+	}
+	var extension = models.RentExtension{
+		RentID:          rent.ID,
+		PreviousEndDate: rent.EndDate,
+		EndDate:         endDate,
+		Amount:          utils.CalculateTotalRent(movies, extendedDays) - utils.CalculateTotalRent(movies, rentDays),
+	}
+
+	tx := rr.db.WithContext(ctx).Begin()
+
+	if len(copyIDs) > 0 {
+		if err = lockCopies(tx, "id IN ?", copyIDs); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		var busy []uint
+		if err = heldCopies(tx, rent.EndDate, endDate).
+			Where("rents.id <> ?", rent.ID).
+			Where("movie_rents.copy_id IN ?", copyIDs).
+			Pluck("movie_rents.copy_id", &busy).Error; err != nil {
 			tx.Rollback()
 			return nil, err
 		}
+		if len(busy) > 0 {
+			tx.Rollback()
+			return nil, utils.ErrMovieUnavailable.Withf("Copy %s is reserved by another rent before %s", barcodes[busy[0]], endDate)
+		}
+	}
+
+	if err = tx.Omit("Rent").Create(&extension).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	result := tx.Model(&models.Rent{}).
+		Where("id = ? AND status = ? AND end_date = ?", rent.ID, rent.Status, rent.EndDate).
+		Updates(map[string]interface{}{
+			"end_date": endDate,
+			"total":    rent.Total + extension.Amount,
+		})
+	if result.Error != nil {
+		tx.Rollback()
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return nil, utils.ErrRentNotExtendable.Withf("The rent changed while it was being extended")
 	}
 
-	if err := tx.Commit().Error; err != nil {
+	if err = tx.Commit().Error; err != nil {
 		return nil, err
 	}
 
-	return &models.RentResponse{
-		UserID:    rent.UserID,
-		Total:     total,
-		Movies:    movies,
-		StartDate: rent.StartDate,
-		EndDate:   rent.EndDate,
-	}, nil
+	rent.EndDate = endDate
+	rent.Total += extension.Amount
+	rent.Extensions = append(rent.Extensions, extension)
+	return newRentResponse(*rent), nil
+}
+
+// updateStatus stores status and changes in rent, failing with
+// ErrInvalidRentTransition when its status changed since it was loaded.
+func (rr *rentRepository) updateStatus(db *gorm.DB, rent *models.Rent, status string, changes map[string]interface{}) error {
+	changes["status"] = status
+	result := db.Model(&models.Rent{}).Where("id = ? AND status = ?", rent.ID, rent.Status).Updates(changes)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrInvalidRentTransition.Withf("The status of the rent changed while it was being updated")
+	}
+	rent.Status = status
+	return nil
+}
+
+// AdvanceStatuses stores the status the rents reached on date as time went by:
+// reserved rents that started are active and those that ended overdue. It
+// returns how many rents changed.
+func (rr *rentRepository) AdvanceStatuses(ctx context.Context, date string) (int64, error) {
+	overdue := rr.db.WithContext(ctx).Model(&models.Rent{}).
+		Where("status IN ? AND end_date < ?", []string{models.RentReserved, models.RentActive}, date).
+		Update("status", models.RentOverdue)
+	if overdue.Error != nil {
+		return 0, overdue.Error
+	}
+	active := rr.db.WithContext(ctx).Model(&models.Rent{}).
+		Where("status = ? AND start_date <= ?", models.RentReserved, date).
+		Update("status", models.RentActive)
+	return overdue.RowsAffected + active.RowsAffected, active.Error
+}
+
+// rentStatusScope filters the rents by their status on date, whether or not
+// it was stored yet.
+func rentStatusScope(status string, date string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		switch status {
+		case models.RentReserved:
+			return db.Where("status = ? AND start_date > ?", models.RentReserved, date)
+		case models.RentActive:
+			return db.Where("status IN ? AND start_date <= ? AND end_date >= ?",
+				[]string{models.RentReserved, models.RentActive}, date, date)
+		case models.RentOverdue:
+			return db.Where("status IN ? AND end_date < ?",
+				[]string{models.RentReserved, models.RentActive, models.RentOverdue}, date)
+		default:
+			return db.Where("status = ?", status)
+		}
+	}
+}
+
+// newRentResponse answers rent with its status today.
+func newRentResponse(rent models.Rent) *models.RentResponse {
+	rent.Status = rent.StatusOn(today())
+	return models.NewRentResponse(rent)
+}
+
+func invalidRentTransition(from string, to string) error {
+	return utils.ErrInvalidRentTransition.Withf("The rent is %s and cannot be %s", from, to)
+}
+
+func today() string {
+	return time.Now().Format(models.DateLayout)
 }
@@ -1,17 +1,21 @@
 package repositories
 
 import (
+	"context"
 	"github/jorgemvv01/go-api/models"
 	"github/jorgemvv01/go-api/utils"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
 )
 
 type TypeRepository interface {
-	Create(movieType *models.Type) error
-	GetByID(id uint) (*models.TypeResponse, error)
-	GetAll() (*[]models.TypeResponse, error)
-	Update(id uint, movieType *models.Type) (*models.TypeResponse, error)
-	Delete(id uint) error
+	Create(ctx context.Context, movieType *models.Type) error
+	GetByID(ctx context.Context, id uint) (*models.TypeResponse, error)
+	GetAll(ctx context.Context, query *models.TypeQuery) (*[]models.TypeResponse, int64, error)
+	Update(ctx context.Context, id uint, movieType *models.Type, version uint) (*models.TypeResponse, error)
+	Delete(ctx context.Context, id uint, reassignTo uint, version uint) error
+	GetAudits(ctx context.Context, id uint) (*[]models.TypeAuditResponse, error)
 }
 
 type typeRepository struct {
@@ -24,52 +28,159 @@ func NewTypeRepository(db *gorm.DB) TypeRepository {
 	}
 }
 
-func (tr *typeRepository) Create(movieType *models.Type) error {
-	return tr.db.Create(&movieType).Error
+func (tr *typeRepository) Create(ctx context.Context, movieType *models.Type) error {
+	return tr.db.WithContext(ctx).Create(&movieType).Error
 }
 
-func (tr *typeRepository) GetByID(id uint) (*models.TypeResponse, error) {
+func (tr *typeRepository) GetByID(ctx context.Context, id uint) (*models.TypeResponse, error) {
 	var movieType *models.Type
-	if err := tr.db.Find(&movieType, id).Error; err != nil {
+	if err := tr.db.WithContext(ctx).Find(&movieType, id).Error; err != nil {
 		return nil, err
 	}
 	return models.NewTypeResponse(*movieType), nil
 }
 
-func (tr *typeRepository) GetAll() (*[]models.TypeResponse, error) {
+var typeSortColumns = map[string]string{
+	"id":   "id",
+	"name": "name",
+}
+
+func (tr *typeRepository) GetAll(ctx context.Context, query *models.TypeQuery) (*[]models.TypeResponse, int64, error) {
+	orderBy, err := sortScope(query.Sort, typeSortColumns)
+	if err != nil {
+		return nil, 0, err
+	}
+	db := tr.db.WithContext(ctx).Model(&models.Type{})
+	if query.Name != "" {
+		db = db.Where("LOWER(name) LIKE ?", "%"+strings.ToLower(query.Name)+"%")
+	}
+	db = db.Session(&gorm.Session{})
+	var total int64
+	if err = db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
 	var movieTypes *[]models.Type
-	if err := tr.db.Find(&movieTypes).Error; err != nil {
-		return nil, err
+	if err = db.Scopes(orderBy, paginateScope(query.PageQuery)).Find(&movieTypes).Error; err != nil {
+		return nil, 0, err
 	}
 	var movieTypeResponse []models.TypeResponse
 	for _, movieType := range *movieTypes {
 		movieTypeResponse = append(movieTypeResponse, *models.NewTypeResponse(movieType))
 	}
-	return &movieTypeResponse, nil
+	return &movieTypeResponse, total, nil
 }
 
-func (tr *typeRepository) Update(id uint, movieType *models.Type) (*models.TypeResponse, error) {
+func (tr *typeRepository) Update(ctx context.Context, id uint, movieType *models.Type, version uint) (*models.TypeResponse, error) {
 	var oldMovieType *models.Type
-	if err := tr.db.Find(&oldMovieType, id).Error; err != nil {
+	if err := tr.db.WithContext(ctx).Find(&oldMovieType, id).Error; err != nil {
 		return nil, err
 	}
 	if oldMovieType.ID == 0 {
 		return nil, utils.ErrNotFound
 	}
-	oldMovieType.Name = movieType.Name
-	if err := tr.db.Save(&oldMovieType).Error; err != nil {
+	if err := checkVersion(oldMovieType.Version, version); err != nil {
+		return nil, err
+	}
+
+	tx := tr.db.WithContext(ctx).Begin()
+
+	if oldMovieType.Name != movieType.Name {
+		var typeAudit = models.TypeAudit{
+			TypeID:  oldMovieType.ID,
+			OldName: oldMovieType.Name,
+			NewName: movieType.Name,
+		}
+		if err := tx.Create(&typeAudit).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err := updateVersioned(tx, &oldMovieType, oldMovieType.Version, map[string]interface{}{
+		"name":                 movieType.Name,
+		"base_days":            movieType.BaseDays,
+		"surcharge_percentage": movieType.SurchargePercentage,
+		"daily_cap":            movieType.DailyCap,
+	}); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
 		return nil, err
 	}
 	return models.NewTypeResponse(*oldMovieType), nil
 }
 
-func (tr *typeRepository) Delete(id uint) error {
+// Delete removes the type only when no movie uses it. If reassignTo is set,
+// the movies of the type are moved to that type before deleting it. The type
+// is locked first, so that the movies counted are all the movies of the type.
+func (tr *typeRepository) Delete(ctx context.Context, id uint, reassignTo uint, version uint) error {
+	tx := tr.db.WithContext(ctx).Begin()
+
 	var movieType *models.Type
-	if err := tr.db.Find(&movieType, id).Error; err != nil {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Find(&movieType, id).Error; err != nil {
+		tx.Rollback()
 		return err
 	}
 	if movieType.ID == 0 {
+		tx.Rollback()
 		return utils.ErrNotFound
 	}
-	return tr.db.Delete(&movieType).Error
+	if err := checkVersion(movieType.Version, version); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var movies int64
+	if err := tx.Model(&models.Movie{}).Where("type_id = ?", id).Count(&movies).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if movies > 0 && reassignTo == 0 {
+		tx.Rollback()
+		return utils.ErrTypeInUse
+	}
+
+	if movies > 0 {
+		var newMovieType *models.Type
+		if err := tx.Find(&newMovieType, reassignTo).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		if newMovieType.ID == 0 || newMovieType.ID == movieType.ID {
+			tx.Rollback()
+			return utils.ErrTypeNotFound
+		}
+		if err := tx.Model(&models.Movie{}).Where("type_id = ?", id).Update("type_id", reassignTo).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := deleteVersioned(tx, &movieType, movieType.Version); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+func (tr *typeRepository) GetAudits(ctx context.Context, id uint) (*[]models.TypeAuditResponse, error) {
+	var movieType *models.Type
+	if err := tr.db.WithContext(ctx).Unscoped().Find(&movieType, id).Error; err != nil {
+		return nil, err
+	}
+	if movieType.ID == 0 {
+		return nil, utils.ErrNotFound
+	}
+	var typeAudits *[]models.TypeAudit
+	if err := tr.db.WithContext(ctx).Where("type_id = ?", id).Order("id").Find(&typeAudits).Error; err != nil {
+		return nil, err
+	}
+	var typeAuditsResponse []models.TypeAuditResponse
+	for _, typeAudit := range *typeAudits {
+		typeAuditsResponse = append(typeAuditsResponse, *models.NewTypeAuditResponse(typeAudit))
+	}
+	return &typeAuditsResponse, nil
 }
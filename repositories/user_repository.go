@@ -1,17 +1,21 @@
 package repositories
 
 import (
+	"context"
 	"github/jorgemvv01/go-api/models"
 	"github/jorgemvv01/go-api/utils"
 	"gorm.io/gorm"
+	"strings"
 )
 
 type UserRepository interface {
-	Create(user *models.User) error
-	GetByID(id uint) (*models.UserResponse, error)
-	GetAll() (*[]models.UserResponse, error)
-	Update(id uint, user *models.User) (*models.UserResponse, error)
-	Delete(id uint) error
+	Create(ctx context.Context, user *models.User) error
+	GetByID(ctx context.Context, id uint) (*models.UserResponse, error)
+	GetByUsername(ctx context.Context, username string) (*models.User, error)
+	GetAll(ctx context.Context, query *models.UserQuery) (*[]models.UserResponse, int64, error)
+	Update(ctx context.Context, id uint, user *models.User, version uint) (*models.UserResponse, error)
+	Patch(ctx context.Context, id uint, patch *models.UserPatch, version uint) (*models.UserResponse, error)
+	Delete(ctx context.Context, id uint, version uint) error
 }
 
 type userRepository struct {
@@ -24,53 +28,180 @@ func NewUserRepository(db *gorm.DB) UserRepository {
 	}
 }
 
-func (ur *userRepository) Create(user *models.User) error {
-	return ur.db.Create(&user).Error
+func (ur *userRepository) Create(ctx context.Context, user *models.User) error {
+	if err := ur.setCredentials(ctx, user, user); err != nil {
+		return err
+	}
+	if user.Role == "" {
+		user.Role = models.RoleCustomer
+	}
+	return ur.db.WithContext(ctx).Create(&user).Error
 }
 
-func (ur *userRepository) GetByID(id uint) (*models.UserResponse, error) {
+// setCredentials copies the username, role and password of user into target,
+// hashing the password. Empty fields are left unchanged.
+func (ur *userRepository) setCredentials(ctx context.Context, target *models.User, user *models.User) error {
+	if user.Username != "" {
+		var count int64
+		if err := ur.db.WithContext(ctx).Model(&models.User{}).
+			Where("username = ? AND id <> ?", user.Username, target.ID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return utils.ErrUsernameAlreadyExists
+		}
+		target.Username = user.Username
+	}
+	if user.Role != "" {
+		target.Role = user.Role
+	}
+	if user.Password != "" {
+		hash, err := utils.HashPassword(user.Password)
+		if err != nil {
+			return err
+		}
+		target.PasswordHash = hash
+		target.Password = ""
+	}
+	return nil
+}
+
+func (ur *userRepository) GetByID(ctx context.Context, id uint) (*models.UserResponse, error) {
 	var user *models.User
-	if err := ur.db.Find(&user, id).Error; err != nil {
+	if err := ur.db.WithContext(ctx).Find(&user, id).Error; err != nil {
 		return nil, err
 	}
 	return models.NewUserResponse(*user), nil
 }
 
-func (ur *userRepository) GetAll() (*[]models.UserResponse, error) {
-	var users *[]models.User
-	if err := ur.db.Find(&users).Error; err != nil {
+func (ur *userRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	var user *models.User
+	if err := ur.db.WithContext(ctx).Where("username = ?", username).Find(&user).Error; err != nil {
 		return nil, err
 	}
+	if user.ID == 0 {
+		return nil, utils.ErrUserNotFound
+	}
+	return user, nil
+}
+
+var userSortColumns = map[string]string{
+	"id":       "id",
+	"surname":  "surname",
+	"lastname": "lastname",
+}
+
+func (ur *userRepository) GetAll(ctx context.Context, query *models.UserQuery) (*[]models.UserResponse, int64, error) {
+	orderBy, err := sortScope(query.Sort, userSortColumns)
+	if err != nil {
+		return nil, 0, err
+	}
+	db := ur.db.WithContext(ctx).Model(&models.User{})
+	if query.Surname != "" {
+		db = db.Where("LOWER(surname) LIKE ?", "%"+strings.ToLower(query.Surname)+"%")
+	}
+	if query.Lastname != "" {
+		db = db.Where("LOWER(lastname) LIKE ?", "%"+strings.ToLower(query.Lastname)+"%")
+	}
+	db = db.Session(&gorm.Session{})
+	var total int64
+	if err = db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var users *[]models.User
+	if err = db.Scopes(orderBy, paginateScope(query.PageQuery)).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
 	var usersResponse []models.UserResponse
 	for _, user := range *users {
 		usersResponse = append(usersResponse, *models.NewUserResponse(user))
 	}
-	return &usersResponse, nil
+	return &usersResponse, total, nil
 }
 
-func (ur *userRepository) Update(id uint, user *models.User) (*models.UserResponse, error) {
+func (ur *userRepository) Update(ctx context.Context, id uint, user *models.User, version uint) (*models.UserResponse, error) {
 	var oldUser *models.User
-	if err := ur.db.Find(&oldUser, id).Error; err != nil {
+	if err := ur.db.WithContext(ctx).Find(&oldUser, id).Error; err != nil {
 		return nil, err
 	}
 	if oldUser.ID == 0 {
 		return nil, utils.ErrNotFound
 	}
-	oldUser.Surname = user.Surname
-	oldUser.Lastname = user.Lastname
-	if err := ur.db.Save(&oldUser).Error; err != nil {
+	if err := checkVersion(oldUser.Version, version); err != nil {
+		return nil, err
+	}
+	if err := ur.setCredentials(ctx, oldUser, user); err != nil {
+		return nil, err
+	}
+	changes := credentialChanges(oldUser, user)
+	changes["surname"] = user.Surname
+	changes["lastname"] = user.Lastname
+	if err := updateVersioned(ur.db.WithContext(ctx), &oldUser, oldUser.Version, changes); err != nil {
 		return nil, err
 	}
 	return models.NewUserResponse(*oldUser), nil
 }
 
-func (ur *userRepository) Delete(id uint) error {
+func (ur *userRepository) Patch(ctx context.Context, id uint, patch *models.UserPatch, version uint) (*models.UserResponse, error) {
 	var user *models.User
-	if err := ur.db.Find(&user, id).Error; err != nil {
+	if err := ur.db.WithContext(ctx).Find(&user, id).Error; err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, utils.ErrNotFound
+	}
+	if err := checkVersion(user.Version, version); err != nil {
+		return nil, err
+	}
+	credentials := &models.User{}
+	if patch.Username != nil {
+		credentials.Username = *patch.Username
+	}
+	if patch.Password != nil {
+		credentials.Password = *patch.Password
+	}
+	if err := ur.setCredentials(ctx, user, credentials); err != nil {
+		return nil, err
+	}
+	changes := credentialChanges(user, credentials)
+	for column, value := range patch.Changes() {
+		changes[column] = value
+	}
+	if len(changes) > 0 {
+		if err := updateVersioned(ur.db.WithContext(ctx), &user, user.Version, changes); err != nil {
+			return nil, err
+		}
+	}
+	return models.NewUserResponse(*user), nil
+}
+
+// credentialChanges returns the columns of target changed by setCredentials
+// from user.
+func credentialChanges(target *models.User, user *models.User) map[string]interface{} {
+	changes := make(map[string]interface{})
+	if user.Username != "" {
+		changes["username"] = target.Username
+	}
+	if user.Role != "" {
+		changes["role"] = target.Role
+	}
+	if user.Password != "" {
+		changes["password_hash"] = target.PasswordHash
+	}
+	return changes
+}
+
+func (ur *userRepository) Delete(ctx context.Context, id uint, version uint) error {
+	var user *models.User
+	if err := ur.db.WithContext(ctx).Find(&user, id).Error; err != nil {
 		return err
 	}
 	if user.ID == 0 {
 		return utils.ErrNotFound
 	}
-	return ur.db.Delete(&user).Error
+	if err := checkVersion(user.Version, version); err != nil {
+		return err
+	}
+	return deleteVersioned(ur.db.WithContext(ctx), &user, user.Version)
 }
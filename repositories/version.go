@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"github/jorgemvv01/go-api/utils"
+	"gorm.io/gorm"
+)
+
+// checkVersion fails with ErrVersionMismatch when the client expects a
+// version other than the current one. A version of 0 matches any.
+func checkVersion(current uint, version uint) error {
+	if version != 0 && version != current {
+		return utils.ErrVersionMismatch
+	}
+	return nil
+}
+
+// updateVersioned writes changes to the row of model and bumps its version,
+// only if the row is still at the current version it was loaded with. The
+// changes are copied into model.
+func updateVersioned(db *gorm.DB, model interface{}, current uint, changes map[string]interface{}) error {
+	changes["version"] = current + 1
+	result := db.Model(model).Where("version = ?", current).Updates(changes)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrVersionMismatch
+	}
+	return nil
+}
+
+// deleteVersioned deletes the row of model, only if it is still at the
+// current version it was loaded with.
+func deleteVersioned(db *gorm.DB, model interface{}, current uint) error {
+	result := db.Where("version = ?", current).Delete(model)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrVersionMismatch
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github/jorgemvv01/go-api/controllers"
+)
+
+func RegisterAuthRoutes(router *gin.RouterGroup, authController controllers.AuthController) {
+	authRouter := router.Group("/auth")
+	authRouter.POST("/login", authController.Login)
+	authRouter.POST("/refresh", authController.Refresh)
+}
@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github/jorgemvv01/go-api/controllers"
+	"github/jorgemvv01/go-api/middlewares"
+	"github/jorgemvv01/go-api/models"
+	"github/jorgemvv01/go-api/utils"
+)
+
+func RegisterCopyRoutes(router *gin.RouterGroup, copyController controllers.CopyController, tokenService utils.TokenService) {
+	staffRouter := router.Group("", middlewares.Authenticate(tokenService), middlewares.RequireRoles(models.RoleClerk, models.RoleAdmin))
+	copyRouter := staffRouter.Group("/copies")
+	copyRouter.POST("/create", copyController.Create)
+	copyRouter.DELETE("/delete/:id", copyController.Delete)
+
+	staffRouter.GET("/movies/:id/copies", copyController.GetByMovieID)
+}
+
+func RegisterCopyRoutesV2(router *gin.RouterGroup, copyController controllers.CopyController, tokenService utils.TokenService) {
+	staffRouter := router.Group("", middlewares.Authenticate(tokenService), middlewares.RequireRoles(models.RoleClerk, models.RoleAdmin))
+	copyRouter := staffRouter.Group("/copies")
+	copyRouter.POST("", copyController.Create)
+	copyRouter.DELETE("/:id", copyController.Delete)
+
+	staffRouter.GET("/movies/:id/copies", copyController.GetByMovieID)
+}
@@ -3,19 +3,30 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github/jorgemvv01/go-api/controllers"
-	"github/jorgemvv01/go-api/repositories"
-	"github/jorgemvv01/go-api/storage"
+	"github/jorgemvv01/go-api/middlewares"
+	"github/jorgemvv01/go-api/models"
+	"github/jorgemvv01/go-api/utils"
 )
 
-func RegisterGenreRoutes(router *gin.RouterGroup) {
-	db := storage.GetInstance()
-	genreRepository := repositories.NewGenreRepository(db)
-	genreController := controllers.NewGenreController(genreRepository)
-
+func RegisterGenreRoutes(router *gin.RouterGroup, genreController controllers.GenreController, tokenService utils.TokenService) {
 	genreRouter := router.Group("/genres")
 	genreRouter.GET("/", genreController.GetAll)
 	genreRouter.GET("/:id", genreController.GetByID)
-	genreRouter.POST("/create", genreController.Create)
-	genreRouter.PUT("/update/:id", genreController.Update)
-	genreRouter.DELETE("/delete/:id", genreController.Delete)
+
+	adminRouter := genreRouter.Group("", middlewares.Authenticate(tokenService), middlewares.RequireRoles(models.RoleAdmin))
+	adminRouter.POST("/create", genreController.Create)
+	adminRouter.PUT("/update/:id", middlewares.RequireIfMatch(), genreController.Update)
+	adminRouter.DELETE("/delete/:id", middlewares.RequireIfMatch(), genreController.Delete)
+}
+
+func RegisterGenreRoutesV2(router *gin.RouterGroup, genreController controllers.GenreController, tokenService utils.TokenService) {
+	genreRouter := router.Group("/genres")
+	genreRouter.GET("", genreController.GetAll)
+	genreRouter.GET("/:id", genreController.GetByID)
+
+	adminRouter := genreRouter.Group("", middlewares.Authenticate(tokenService), middlewares.RequireRoles(models.RoleAdmin))
+	adminRouter.POST("", genreController.Create)
+	adminRouter.PUT("/:id", middlewares.RequireIfMatch(), genreController.Update)
+	adminRouter.PATCH("/:id", middlewares.RequireIfMatch(), genreController.Patch)
+	adminRouter.DELETE("/:id", middlewares.RequireIfMatch(), genreController.Delete)
 }
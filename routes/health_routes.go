@@ -0,0 +1,11 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github/jorgemvv01/go-api/controllers"
+)
+
+func RegisterHealthRoutes(router gin.IRouter, healthController controllers.HealthController) {
+	router.GET("/healthz", healthController.Liveness)
+	router.GET("/readyz", healthController.Readiness)
+}
@@ -0,0 +1,10 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github/jorgemvv01/go-api/metrics"
+)
+
+func RegisterMetricsRoutes(router gin.IRouter, m *metrics.Metrics) {
+	router.GET("/metrics", gin.WrapH(m.Handler()))
+}
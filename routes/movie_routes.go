@@ -3,19 +3,32 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github/jorgemvv01/go-api/controllers"
-	"github/jorgemvv01/go-api/repositories"
-	"github/jorgemvv01/go-api/storage"
+	"github/jorgemvv01/go-api/middlewares"
+	"github/jorgemvv01/go-api/models"
+	"github/jorgemvv01/go-api/utils"
 )
 
-func RegisterMovieRouter(router *gin.RouterGroup) {
-	db := storage.GetInstance()
-	movieRepository := repositories.NewMovieRepository(db)
-	movieController := controllers.NewMovieController(movieRepository)
+func RegisterMovieRouter(router *gin.RouterGroup, movieController controllers.MovieController, tokenService utils.TokenService) {
+	movieRouter := router.Group("/movies")
+	movieRouter.GET("", movieController.GetAll)
+	movieRouter.GET("/search", movieController.Search)
+	movieRouter.GET("/:id", movieController.GetByID)
 
+	adminRouter := movieRouter.Group("", middlewares.Authenticate(tokenService), middlewares.RequireRoles(models.RoleAdmin))
+	adminRouter.POST("/create", movieController.Create)
+	adminRouter.PUT("/update/:id", middlewares.RequireIfMatch(), movieController.Update)
+	adminRouter.DELETE("/delete/:id", middlewares.RequireIfMatch(), movieController.Delete)
+}
+
+func RegisterMovieRoutesV2(router *gin.RouterGroup, movieController controllers.MovieController, tokenService utils.TokenService) {
 	movieRouter := router.Group("/movies")
 	movieRouter.GET("", movieController.GetAll)
+	movieRouter.GET("/search", movieController.Search)
 	movieRouter.GET("/:id", movieController.GetByID)
-	movieRouter.POST("/create", movieController.Create)
-	movieRouter.PUT("/update/:id", movieController.Update)
-	movieRouter.DELETE("/delete/:id", movieController.Delete)
+
+	adminRouter := movieRouter.Group("", middlewares.Authenticate(tokenService), middlewares.RequireRoles(models.RoleAdmin))
+	adminRouter.POST("", movieController.Create)
+	adminRouter.PUT("/:id", middlewares.RequireIfMatch(), movieController.Update)
+	adminRouter.PATCH("/:id", middlewares.RequireIfMatch(), movieController.Patch)
+	adminRouter.DELETE("/:id", middlewares.RequireIfMatch(), movieController.Delete)
 }
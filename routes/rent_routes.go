@@ -3,15 +3,34 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github/jorgemvv01/go-api/controllers"
+	"github/jorgemvv01/go-api/middlewares"
+	"github/jorgemvv01/go-api/models"
 	"github/jorgemvv01/go-api/repositories"
-	"github/jorgemvv01/go-api/storage"
+	"github/jorgemvv01/go-api/utils"
 )
 
-func RegisterRentRoutes(router *gin.RouterGroup) {
-	db := storage.GetInstance()
-	rentRepository := repositories.NewRentRepository(db)
-	rentController := controllers.NewRentController(rentRepository)
+func RegisterRentRoutes(router *gin.RouterGroup, rentController controllers.RentController, tokenService utils.TokenService, idempotencyRepository repositories.IdempotencyRepository) {
+	authenticate := middlewares.Authenticate(tokenService)
+	rentRouter := router.Group("/rent", authenticate)
+	rentRouter.GET("", rentController.GetAll)
+	rentRouter.GET("/:id", rentController.GetByID)
+	rentRouter.POST("/create", middlewares.Idempotent(idempotencyRepository), rentController.Create)
+	rentRouter.POST("/:id/return", middlewares.RequireRoles(models.RoleClerk, models.RoleAdmin), rentController.Return)
+	rentRouter.POST("/:id/cancel", middlewares.RequireRoles(models.RoleClerk, models.RoleAdmin), rentController.Cancel)
+	rentRouter.POST("/:id/extend", middlewares.RequireRoles(models.RoleClerk, models.RoleAdmin), rentController.Extend)
 
-	genreRouter := router.Group("/rent")
-	genreRouter.POST("/create", rentController.Create)
+	router.GET("/users/:id/rents", authenticate, rentController.GetByUserID)
+}
+
+func RegisterRentRoutesV2(router *gin.RouterGroup, rentController controllers.RentController, tokenService utils.TokenService, idempotencyRepository repositories.IdempotencyRepository) {
+	authenticate := middlewares.Authenticate(tokenService)
+	rentRouter := router.Group("/rents", authenticate)
+	rentRouter.GET("", rentController.GetAll)
+	rentRouter.GET("/:id", rentController.GetByID)
+	rentRouter.POST("", middlewares.Idempotent(idempotencyRepository), rentController.Create)
+	rentRouter.POST("/:id/return", middlewares.RequireRoles(models.RoleClerk, models.RoleAdmin), rentController.Return)
+	rentRouter.POST("/:id/cancel", middlewares.RequireRoles(models.RoleClerk, models.RoleAdmin), rentController.Cancel)
+	rentRouter.POST("/:id/extend", middlewares.RequireRoles(models.RoleClerk, models.RoleAdmin), rentController.Extend)
+
+	router.GET("/users/:id/rents", authenticate, rentController.GetByUserID)
 }
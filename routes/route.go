@@ -4,18 +4,57 @@ import (
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"github/jorgemvv01/go-api/controllers"
+	"github/jorgemvv01/go-api/metrics"
+	"github/jorgemvv01/go-api/middlewares"
+	"github/jorgemvv01/go-api/repositories"
+	"github/jorgemvv01/go-api/utils"
+	"log/slog"
 )
 
-func SetupRoutes() *gin.Engine {
-	router := gin.Default()
-	api := router.Group("/api")
+// Controllers holds the controllers whose handlers are registered by
+// SetupRoutes.
+type Controllers struct {
+	Health controllers.HealthController
+	Auth   controllers.AuthController
+	User   controllers.UserController
+	Type   controllers.TypeController
+	Genre  controllers.GenreController
+	Movie  controllers.MovieController
+	Copy   controllers.CopyController
+	Rent   controllers.RentController
+}
+
+func SetupRoutes(ctrl *Controllers, tokenService utils.TokenService, idempotencyRepository repositories.IdempotencyRepository, logger *slog.Logger, m *metrics.Metrics) *gin.Engine {
+	router := gin.New()
+	router.Use(middlewares.RequestID(logger), middlewares.AccessLog(), m.Middleware(), middlewares.Recovery(), middlewares.Errors())
+	router.NoRoute(func(c *gin.Context) {
+		middlewares.AbortWithError(c, utils.ErrRouteNotFound.Withf("No route for %s %s", c.Request.Method, c.Request.URL.Path))
+	})
+	RegisterHealthRoutes(router, ctrl.Health)
+	RegisterMetricsRoutes(router, m)
+	router.GET("/api/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+
+	// v1 keeps the original verb-style layout until clients move to v2.
+	api := router.Group("/api", middlewares.Deprecated("/api/v2"))
+	{
+		RegisterAuthRoutes(api, ctrl.Auth)
+		RegisterUserRouter(api, ctrl.User, tokenService)
+		RegisterTypeRoutes(api, ctrl.Type, tokenService)
+		RegisterGenreRoutes(api, ctrl.Genre, tokenService)
+		RegisterMovieRouter(api, ctrl.Movie, tokenService)
+		RegisterCopyRoutes(api, ctrl.Copy, tokenService)
+		RegisterRentRoutes(api, ctrl.Rent, tokenService, idempotencyRepository)
+	}
+	v2 := router.Group("/api/v2")
 	{
-		api.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
-		RegisterUserRouter(api)
-		RegisterTypeRoutes(api)
-		RegisterGenreRoutes(api)
-		RegisterMovieRouter(api)
-		RegisterRentRoutes(api)
+		RegisterAuthRoutes(v2, ctrl.Auth)
+		RegisterUserRoutesV2(v2, ctrl.User, tokenService)
+		RegisterTypeRoutesV2(v2, ctrl.Type, tokenService)
+		RegisterGenreRoutesV2(v2, ctrl.Genre, tokenService)
+		RegisterMovieRoutesV2(v2, ctrl.Movie, tokenService)
+		RegisterCopyRoutesV2(v2, ctrl.Copy, tokenService)
+		RegisterRentRoutesV2(v2, ctrl.Rent, tokenService, idempotencyRepository)
 	}
 
 	return router
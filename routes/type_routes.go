@@ -3,19 +3,31 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github/jorgemvv01/go-api/controllers"
-	"github/jorgemvv01/go-api/repositories"
-	"github/jorgemvv01/go-api/storage"
+	"github/jorgemvv01/go-api/middlewares"
+	"github/jorgemvv01/go-api/models"
+	"github/jorgemvv01/go-api/utils"
 )
 
-func RegisterTypeRoutes(router *gin.RouterGroup) {
-	db := storage.GetInstance()
-	typeRepository := repositories.NewTypeRepository(db)
-	typeController := controllers.NewTypeController(typeRepository)
-
+func RegisterTypeRoutes(router *gin.RouterGroup, typeController controllers.TypeController, tokenService utils.TokenService) {
 	typeRouter := router.Group("/types")
 	typeRouter.GET("/", typeController.GetAll)
 	typeRouter.GET("/:id", typeController.GetByID)
-	//typeRouter.POST("/create", typeController.Create)
-	//typeRouter.PUT("/update/:id", typeController.Update)
-	//typeRouter.DELETE("/delete/:id", typeController.Delete)
+
+	adminRouter := typeRouter.Group("", middlewares.Authenticate(tokenService), middlewares.RequireRoles(models.RoleAdmin))
+	adminRouter.GET("/:id/audits", typeController.GetAudits)
+	adminRouter.POST("/create", typeController.Create)
+	adminRouter.PUT("/update/:id", middlewares.RequireIfMatch(), typeController.Update)
+	adminRouter.DELETE("/delete/:id", middlewares.RequireIfMatch(), typeController.Delete)
+}
+
+func RegisterTypeRoutesV2(router *gin.RouterGroup, typeController controllers.TypeController, tokenService utils.TokenService) {
+	typeRouter := router.Group("/types")
+	typeRouter.GET("", typeController.GetAll)
+	typeRouter.GET("/:id", typeController.GetByID)
+
+	adminRouter := typeRouter.Group("", middlewares.Authenticate(tokenService), middlewares.RequireRoles(models.RoleAdmin))
+	adminRouter.GET("/:id/audits", typeController.GetAudits)
+	adminRouter.POST("", typeController.Create)
+	adminRouter.PUT("/:id", middlewares.RequireIfMatch(), typeController.Update)
+	adminRouter.DELETE("/:id", middlewares.RequireIfMatch(), typeController.Delete)
 }
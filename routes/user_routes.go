@@ -3,19 +3,28 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github/jorgemvv01/go-api/controllers"
-	"github/jorgemvv01/go-api/repositories"
-	"github/jorgemvv01/go-api/storage"
+	"github/jorgemvv01/go-api/middlewares"
+	"github/jorgemvv01/go-api/models"
+	"github/jorgemvv01/go-api/utils"
 )
 
-func RegisterUserRouter(router *gin.RouterGroup) {
-	db := storage.GetInstance()
-	userRepository := repositories.NewUserRepository(db)
-	userController := controllers.NewUserController(userRepository)
+func RegisterUserRouter(router *gin.RouterGroup, userController controllers.UserController, tokenService utils.TokenService) {
+	staff := middlewares.RequireRoles(models.RoleClerk, models.RoleAdmin)
+	userRouter := router.Group("/users", middlewares.Authenticate(tokenService))
+	userRouter.GET("", staff, userController.GetAll)
+	userRouter.GET("/:id", userController.GetByID)
+	userRouter.POST("/create", staff, userController.Create)
+	userRouter.PUT("/update/:id", staff, middlewares.RequireIfMatch(), userController.Update)
+	userRouter.DELETE("/delete/:id", middlewares.RequireRoles(models.RoleAdmin), middlewares.RequireIfMatch(), userController.Delete)
+}
 
-	userRouter := router.Group("/users")
-	userRouter.GET("", userController.GetAll)
+func RegisterUserRoutesV2(router *gin.RouterGroup, userController controllers.UserController, tokenService utils.TokenService) {
+	staff := middlewares.RequireRoles(models.RoleClerk, models.RoleAdmin)
+	userRouter := router.Group("/users", middlewares.Authenticate(tokenService))
+	userRouter.GET("", staff, userController.GetAll)
 	userRouter.GET("/:id", userController.GetByID)
-	userRouter.POST("/create", userController.Create)
-	userRouter.PUT("/update/:id", userController.Update)
-	userRouter.DELETE("/delete/:id", userController.Delete)
+	userRouter.POST("", staff, userController.Create)
+	userRouter.PUT("/:id", staff, middlewares.RequireIfMatch(), userController.Update)
+	userRouter.PATCH("/:id", staff, middlewares.RequireIfMatch(), userController.Patch)
+	userRouter.DELETE("/:id", middlewares.RequireRoles(models.RoleAdmin), middlewares.RequireIfMatch(), userController.Delete)
 }
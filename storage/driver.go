@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"fmt"
+	"github/jorgemvv01/go-api/config"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// dialector returns the GORM dialector of the configured driver. The
+// drivers accepted here must match the ones accepted by config.Validate.
+func dialector(cfg config.DatabaseConfig) (gorm.Dialector, error) {
+	switch cfg.Driver {
+	case config.DriverPostgres:
+		return postgres.Open(cfg.DSN), nil
+	case config.DriverSQLite:
+		return sqlite.Open(cfg.DSN), nil
+	case config.DriverMySQL:
+		return mysql.Open(cfg.DSN), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.Driver)
+	}
+}
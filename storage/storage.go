@@ -1,55 +1,89 @@
 package storage
 
 import (
+	"fmt"
+	"github/jorgemvv01/go-api/config"
+	"github/jorgemvv01/go-api/logging"
 	"github/jorgemvv01/go-api/models"
-	"gorm.io/driver/postgres"
+	"github/jorgemvv01/go-api/utils"
 	"gorm.io/gorm"
-	"os"
+	"log/slog"
 )
 
-var db *gorm.DB
-
-func GetInstance() *gorm.DB {
-	if db == nil {
-		var err error
-		dsn := os.Getenv("DATABASE_URL")
-		db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
-		if err != nil {
-			panic("failed to connect database")
-		}
+// Open opens the database described by cfg.
+func Open(cfg config.DatabaseConfig, logger *slog.Logger) (*gorm.DB, error) {
+	dial, err := dialector(cfg)
+	if err != nil {
+		return nil, err
 	}
-	return db
-}
-
-func MigrateModels(db *gorm.DB) {
-	if err := db.AutoMigrate(
-		&models.User{},
-		&models.Rent{},
-		&models.Type{},
-		&models.Genre{},
-		&models.Movie{},
-		&models.MovieRent{},
-	); err != nil {
-		panic("failed to migrate models")
+	conn, err := gorm.Open(dial, &gorm.Config{
+		Logger: logging.NewGormLogger(logger),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect database: %w", err)
 	}
+	sqlDB, err := conn.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection pool: %w", err)
+	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	return conn, nil
+}
 
-	tx := db.Begin()
-
+// Seed creates the default movie types and the administrator given in the
+// configuration when they do not exist yet. It can run any number of times.
+func Seed(db *gorm.DB, cfg config.AuthConfig) error {
 	var movieTypes = []models.Type{
 		{Name: "New releases"},
-		{Name: "Regular movies"},
-		{Name: "Old movies"},
+		{Name: "Regular movies", PricingRule: models.PricingRule{BaseDays: 3, SurchargePercentage: 15}},
+		{Name: "Old movies", PricingRule: models.PricingRule{BaseDays: 5, SurchargePercentage: 10}},
 	}
-
-	for _, t := range movieTypes {
-		if err := tx.Create(&t).Error; err != nil {
-			tx.Rollback()
-			panic("failed to create movie types")
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, t := range movieTypes {
+			// Deleted types count too, an administrator may have removed one.
+			var count int64
+			if err := tx.Unscoped().Model(&models.Type{}).Where("name = ?", t.Name).Count(&count).Error; err != nil {
+				return fmt.Errorf("failed to look up movie type %s: %w", t.Name, err)
+			}
+			if count > 0 {
+				continue
+			}
+			if err := tx.Create(&t).Error; err != nil {
+				return fmt.Errorf("failed to create movie type %s: %w", t.Name, err)
+			}
 		}
-	}
+		return seedAdmin(tx, cfg.AdminUsername, cfg.AdminPassword)
+	})
+}
 
-	if err := tx.Commit().Error; err != nil {
-		panic("failed to commit types transaction")
+// seedAdmin creates the administrator so that the API can be used before any
+// other user is registered.
+func seedAdmin(tx *gorm.DB, username string, password string) error {
+	if username == "" || password == "" {
+		return nil
 	}
-
+	var count int64
+	if err := tx.Model(&models.User{}).Where("username = ?", username).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to look up admin user: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash admin password: %w", err)
+	}
+	admin := models.User{
+		Surname:      "Admin",
+		Lastname:     "Admin",
+		Username:     username,
+		PasswordHash: hash,
+		Role:         models.RoleAdmin,
+	}
+	if err = tx.Create(&admin).Error; err != nil {
+		return fmt.Errorf("failed to create admin user: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,122 @@
+package tests_app
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github/jorgemvv01/go-api/app"
+	"github/jorgemvv01/go-api/config"
+	"github/jorgemvv01/go-api/migrations"
+	"github/jorgemvv01/go-api/storage"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestApp builds the whole API on a migrated and seeded SQLite database.
+func newTestApp(t *testing.T) *app.App {
+	gin.SetMode(gin.TestMode)
+	cfg := config.Default()
+	cfg.Database.Driver = config.DriverSQLite
+	cfg.Database.DSN = "file:" + filepath.Join(t.TempDir(), "videoclub.db") + "?_foreign_keys=on"
+	cfg.Auth.JWTSecret = "test-secret"
+	cfg.Auth.AdminUsername = "admin"
+	cfg.Auth.AdminPassword = "secret"
+	cfg.LogLevel = config.LogLevelError
+
+	a, err := app.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := a.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+	if _, err = migrations.Up(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	if err = storage.Seed(a.DB, cfg.Auth); err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// do sends a JSON request through the router and decodes the response.
+func do(t *testing.T, a *app.App, method string, path string, body string, token string) (int, map[string]interface{}) {
+	return doWithHeaders(t, a, method, path, body, token, nil)
+}
+
+func doWithHeaders(t *testing.T, a *app.App, method string, path string, body string, token string, headers map[string]string) (int, map[string]interface{}) {
+	rr := serve(a, method, path, body, token, headers)
+	var response map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("%s %s: invalid response body %q", method, path, rr.Body.String())
+	}
+	return rr.Code, response
+}
+
+func serve(a *app.App, method string, path string, body string, token string, headers map[string]string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	rr := httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, request)
+	return rr
+}
+
+func login(t *testing.T, a *app.App, username string, password string) string {
+	status, response := do(t, a, "POST", "/api/auth/login", `{"username":"`+username+`","password":"`+password+`"}`, "")
+	if status != http.StatusOK {
+		t.Fatalf("Unable to login as %s: %v", username, response)
+	}
+	return response["data"].(map[string]interface{})["access_token"].(string)
+}
+
+func TestRentFlow(t *testing.T) {
+	a := newTestApp(t)
+	admin := login(t, a, "admin", "secret")
+
+	steps := []struct {
+		method string
+		path   string
+		body   string
+		token  string
+		status int
+	}{
+		{"DELETE", "/api/users/delete/1", "", "", http.StatusUnauthorized},
+		{"POST", "/api/genres/create", `{"name":"Action"}`, admin, http.StatusOK},
+		{"POST", "/api/movies/create", `{"name":"John Wick: Chapter 4","overview":"John Wick uncovers a path to defeating The High Table.","price":10,"type_id":2,"genre_id":1,"release_date":"2023-03-22"}`, admin, http.StatusOK},
+		{"POST", "/api/copies/create", `{"movie_id":1,"barcode":"WICK-001"}`, admin, http.StatusOK},
+		{"POST", "/api/users/create", `{"surname":"John","lastname":"Doe","username":"jdoe","password":"secret"}`, admin, http.StatusOK},
+	}
+	for _, step := range steps {
+		if status, response := do(t, a, step.method, step.path, step.body, step.token); status != step.status {
+			t.Fatalf("%s %s: got %v want %v: %v", step.method, step.path, status, step.status, response)
+		}
+	}
+
+	customer := login(t, a, "jdoe", "secret")
+	status, response := do(t, a, "POST", "/api/rent/create", `{"user_id":2,"movie_ids":[1],"start_date":"2099-04-07","end_date":"2099-04-12"}`, customer)
+	if status != http.StatusOK {
+		t.Fatalf("Unable to create rent: %v", response)
+	}
+	// Regular movies: 3 days at 10 and 2 days at 11.5.
+	if total := response["data"].(map[string]interface{})["total"]; total != 53.0 {
+		t.Errorf("Unexpected total: got %v want %v", total, 53)
+	}
+
+	status, response = do(t, a, "GET", "/api/rent", "", customer)
+	if status != http.StatusOK || len(response["data"].([]interface{})) != 1 {
+		t.Errorf("Unexpected rents: %v", response)
+	}
+	if status, _ = do(t, a, "GET", "/api/users/1", "", customer); status != http.StatusForbidden {
+		t.Errorf("Customer got another user: %v", status)
+	}
+}
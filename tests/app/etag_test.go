@@ -0,0 +1,73 @@
+package tests_app
+
+import (
+	"encoding/json"
+	"github/jorgemvv01/go-api/models"
+	"net/http"
+	"testing"
+)
+
+func TestOptimisticConcurrency(t *testing.T) {
+	a := newTestApp(t)
+	admin := login(t, a, "admin", "secret")
+
+	if status, response := do(t, a, "POST", "/api/v2/genres", `{"name":"Action"}`, admin); status != http.StatusOK {
+		t.Fatalf("Create genre: got %v: %v", status, response)
+	}
+	movie := `{"name":"John Wick: Chapter 4","overview":"John Wick uncovers a path to defeating The High Table.","price":10,"type_id":2,"genre_id":1,"release_date":"2023-03-22"}`
+	if status, response := do(t, a, "POST", "/api/v2/movies", movie, admin); status != http.StatusOK {
+		t.Fatalf("Create movie: got %v: %v", status, response)
+	}
+
+	rr := serve(a, "GET", "/api/v2/movies/1", "", "", nil)
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"1"` {
+		t.Fatalf("Get movie: got %v with ETag %q", rr.Code, rr.Header().Get("ETag"))
+	}
+	rr = serve(a, "GET", "/api/v2/movies/1", "", "", map[string]string{"If-None-Match": `"1"`})
+	if rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+		t.Errorf("Get current movie: got %v with body %q", rr.Code, rr.Body.String())
+	}
+
+	steps := []struct {
+		method  string
+		path    string
+		body    string
+		ifMatch string
+		status  int
+		code    string
+		etag    string
+	}{
+		{"PUT", "/api/v2/movies/1", movie, "", http.StatusPreconditionRequired, "PRECONDITION_REQUIRED", ""},
+		{"PATCH", "/api/v2/movies/1", `{"price":8.5}`, `"1"`, http.StatusOK, "", `"2"`},
+		// A second clerk still holding the first version.
+		{"PUT", "/api/v2/movies/1", movie, `"1"`, http.StatusPreconditionFailed, "VERSION_MISMATCH", ""},
+		{"DELETE", "/api/v2/movies/1", "", `"1"`, http.StatusPreconditionFailed, "VERSION_MISMATCH", ""},
+		{"PATCH", "/api/v2/movies/1", `{"price":9}`, `W/"2"`, http.StatusPreconditionFailed, "VERSION_MISMATCH", ""},
+		{"PUT", "/api/v2/movies/1", movie, `"2"`, http.StatusOK, "", `"3"`},
+		// The deprecated routes require If-Match too.
+		{"PUT", "/api/movies/update/1", movie, "", http.StatusPreconditionRequired, "PRECONDITION_REQUIRED", ""},
+		{"DELETE", "/api/movies/delete/1", "", "", http.StatusPreconditionRequired, "PRECONDITION_REQUIRED", ""},
+		{"PUT", "/api/movies/update/1", movie, `"2"`, http.StatusPreconditionFailed, "VERSION_MISMATCH", ""},
+		{"PUT", "/api/movies/update/1", movie, `"3"`, http.StatusOK, "", `"4"`},
+		{"DELETE", "/api/v2/movies/1", "", `"4"`, http.StatusOK, "", ""},
+	}
+	for _, step := range steps {
+		headers := map[string]string{}
+		if step.ifMatch != "" {
+			headers["If-Match"] = step.ifMatch
+		}
+		rr = serve(a, step.method, step.path, step.body, admin, headers)
+		if rr.Code != step.status {
+			t.Fatalf("%s %s If-Match %s: got %v want %v: %s", step.method, step.path, step.ifMatch, rr.Code, step.status, rr.Body.String())
+		}
+		if step.code != "" {
+			var problem models.Problem
+			if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil || problem.Code != step.code {
+				t.Errorf("%s %s If-Match %s: expected code %s in %s", step.method, step.path, step.ifMatch, step.code, rr.Body.String())
+			}
+		}
+		if step.etag != "" && rr.Header().Get("ETag") != step.etag {
+			t.Errorf("%s %s If-Match %s: got ETag %q want %q", step.method, step.path, step.ifMatch, rr.Header().Get("ETag"), step.etag)
+		}
+	}
+}
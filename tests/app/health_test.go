@@ -0,0 +1,84 @@
+package tests_app
+
+import (
+	"context"
+	"errors"
+	"github/jorgemvv01/go-api/migrations"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type failingWorker struct{}
+
+func (failingWorker) Name() string {
+	return "failing"
+}
+
+func (failingWorker) Run(context.Context) error {
+	return errors.New("queue unreachable")
+}
+
+func readinessCheck(t *testing.T, response map[string]interface{}, name string) map[string]interface{} {
+	check, ok := response["checks"].(map[string]interface{})[name].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Missing %s check: %v", name, response)
+	}
+	if _, ok = check["latency_ms"].(float64); !ok {
+		t.Errorf("Missing %s check latency: %v", name, check)
+	}
+	return check
+}
+
+func TestHealth(t *testing.T) {
+	a := newTestApp(t)
+
+	if status, response := do(t, a, "GET", "/healthz", "", ""); status != http.StatusOK || response["status"] != "ok" {
+		t.Errorf("Unexpected liveness: %v %v", status, response)
+	}
+
+	status, response := do(t, a, "GET", "/readyz", "", "")
+	if status != http.StatusOK || response["status"] != "ok" {
+		t.Errorf("Unexpected readiness: %v %v", status, response)
+	}
+	for _, name := range []string{"database", "migrations", "workers"} {
+		if check := readinessCheck(t, response, name); check["status"] != "ok" {
+			t.Errorf("Check %s failed: %v", name, check)
+		}
+	}
+
+	if _, err := migrations.Down(a.DB, 1); err != nil {
+		t.Fatal(err)
+	}
+	status, response = do(t, a, "GET", "/readyz", "", "")
+	// The reason is stable, the error itself is only logged.
+	if check := readinessCheck(t, response, "migrations"); status != http.StatusServiceUnavailable || check["status"] != "failed" || check["error"] != "migrations behind" {
+		t.Errorf("Pending migrations not reported: %v %v", status, response)
+	}
+}
+
+func TestReadinessWorkerStopped(t *testing.T) {
+	a := newTestApp(t)
+	a.AddWorker(failingWorker{})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- a.Serve(ctx, listener)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	status, response := do(t, a, "GET", "/readyz", "", "")
+	if check := readinessCheck(t, response, "workers"); status != http.StatusServiceUnavailable || check["status"] != "failed" || check["error"] != "worker stopped" {
+		t.Errorf("Stopped worker not reported: %v %v", status, response)
+	}
+	cancel()
+	if err = <-served; err == nil {
+		t.Error("Expected the worker error on shutdown")
+	}
+}
@@ -0,0 +1,209 @@
+package tests_app
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github/jorgemvv01/go-api/middlewares"
+	"github/jorgemvv01/go-api/models"
+	"github/jorgemvv01/go-api/repositories"
+	"github/jorgemvv01/go-api/utils"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func countRents(t *testing.T, db *gorm.DB, want int64) {
+	t.Helper()
+	var count int64
+	if err := db.Model(&models.Rent{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != want {
+		t.Errorf("Unexpected number of rents: got %v want %v", count, want)
+	}
+}
+
+func TestIdempotentRentCreation(t *testing.T) {
+	a := newTestApp(t)
+	admin := login(t, a, "admin", "secret")
+
+	setup := []struct {
+		path string
+		body string
+	}{
+		{"/api/v2/genres", `{"name":"Action"}`},
+		{"/api/v2/movies", `{"name":"John Wick: Chapter 4","overview":"John Wick uncovers a path to defeating The High Table.","price":10,"type_id":2,"genre_id":1,"release_date":"2023-03-22"}`},
+		{"/api/v2/copies", `{"movie_id":1,"barcode":"WICK-001"}`},
+		{"/api/v2/copies", `{"movie_id":1,"barcode":"WICK-002"}`},
+		{"/api/v2/copies", `{"movie_id":1,"barcode":"WICK-003"}`},
+		{"/api/v2/users", `{"surname":"John","lastname":"Doe","username":"jdoe","password":"secret"}`},
+	}
+	for _, step := range setup {
+		if status, response := do(t, a, "POST", step.path, step.body, admin); status != http.StatusOK {
+			t.Fatalf("POST %s: got %v: %v", step.path, status, response)
+		}
+	}
+
+	rent := `{"user_id":2,"movie_ids":[1],"start_date":"2099-04-07","end_date":"2099-04-12"}`
+	first := serve(a, "POST", "/api/v2/rents", rent, admin, map[string]string{"Idempotency-Key": "pos-1-0001"})
+	if first.Code != http.StatusOK {
+		t.Fatalf("Unable to create rent: %s", first.Body.String())
+	}
+
+	// The terminal timed out and retries.
+	retry := serve(a, "POST", "/api/v2/rents", rent, admin, map[string]string{"Idempotency-Key": "pos-1-0001"})
+	if retry.Code != http.StatusOK || retry.Body.String() != first.Body.String() {
+		t.Errorf("Retry answered %v %s, want the original %s", retry.Code, retry.Body.String(), first.Body.String())
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("Retry was not flagged as replayed: %v", retry.Header())
+	}
+	countRents(t, a.DB, 1)
+
+	other := `{"user_id":2,"movie_ids":[1],"start_date":"2099-04-07","end_date":"2099-04-20"}`
+	status, problem := doProblem(t, "POST", "/api/v2/rents", other, admin, func(rr *httptest.ResponseRecorder, r *http.Request) {
+		r.Header.Set("Idempotency-Key", "pos-1-0001")
+		a.Router.ServeHTTP(rr, r)
+	})
+	if status != http.StatusUnprocessableEntity || problem.Code != "IDEMPOTENCY_KEY_REUSED" {
+		t.Errorf("Reused key answered %v %s", status, problem.Code)
+	}
+
+	// Failed requests release their key.
+	missing := `{"user_id":9,"movie_ids":[1],"start_date":"2099-04-07","end_date":"2099-04-12"}`
+	if rr := serve(a, "POST", "/api/v2/rents", missing, admin, map[string]string{"Idempotency-Key": "pos-1-0002"}); rr.Code != http.StatusNotFound {
+		t.Fatalf("Rent for a missing user answered %v %s", rr.Code, rr.Body.String())
+	}
+	if rr := serve(a, "POST", "/api/v2/rents", rent, admin, map[string]string{"Idempotency-Key": "pos-1-0002"}); rr.Code != http.StatusOK || rr.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("Retry of a failed request answered %v %s", rr.Code, rr.Body.String())
+	}
+	countRents(t, a.DB, 2)
+
+	// Expired keys are forgotten.
+	if err := a.DB.Model(&models.IdempotencyRecord{}).Where("idempotency_key = ?", "pos-1-0001").
+		Update("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if rr := serve(a, "POST", "/api/v2/rents", rent, admin, map[string]string{"Idempotency-Key": "pos-1-0001"}); rr.Code != http.StatusOK || rr.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("Request with an expired key answered %v %s", rr.Code, rr.Body.String())
+	}
+	countRents(t, a.DB, 3)
+
+	// Without the header nothing changes.
+	if status, response := do(t, a, "POST", "/api/v2/rents", rent, admin); status != http.StatusConflict {
+		t.Errorf("Rent without copies left answered %v: %v", status, response)
+	}
+}
+
+func TestIdempotencyRepository(t *testing.T) {
+	a := newTestApp(t)
+	ctx := context.Background()
+	keys := repositories.NewIdempotencyRepository(a.DB, time.Hour, time.Minute)
+
+	record, err := keys.Reserve(ctx, 1, "key", "fingerprint")
+	if err != nil || record.Status != 0 {
+		t.Fatalf("Unable to reserve a key: %v %v", record, err)
+	}
+	if _, err = keys.Reserve(ctx, 1, "key", "fingerprint"); !errors.Is(err, utils.ErrIdempotencyKeyInUse) {
+		t.Errorf("Reserved a key in use: %v", err)
+	}
+	if _, err = keys.Reserve(ctx, 2, "key", "other"); err != nil {
+		t.Errorf("Keys of other users collide: %v", err)
+	}
+	if err = keys.Complete(ctx, record, http.StatusOK, "application/json", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if stored, err := keys.Reserve(ctx, 1, "key", "fingerprint"); err != nil || stored.Status != http.StatusOK || string(stored.Body) != `{}` {
+		t.Errorf("Unexpected stored response: %v %v", stored, err)
+	}
+
+	// Reservations whose request never finished expire with their lease.
+	expired := repositories.NewIdempotencyRepository(a.DB, time.Hour, -time.Minute)
+	lost, err := expired.Reserve(ctx, 3, "key", "fingerprint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = keys.Reserve(ctx, 3, "key", "fingerprint"); err != nil {
+		t.Errorf("Key kept after its lease: %v", err)
+	}
+	// The request that lost its reservation cannot store its response.
+	if err = keys.Renew(ctx, lost); !errors.Is(err, utils.ErrIdempotencyLeaseLost) {
+		t.Errorf("Renewed a lost reservation: %v", err)
+	}
+	if err = keys.Complete(ctx, lost, http.StatusOK, "application/json", []byte(`{}`)); !errors.Is(err, utils.ErrIdempotencyLeaseLost) {
+		t.Errorf("Completed a lost reservation: %v", err)
+	}
+	if _, err = expired.Reserve(ctx, 4, "key", "fingerprint"); err != nil {
+		t.Fatal(err)
+	}
+	if deleted, err := keys.DeleteExpired(ctx); err != nil || deleted != 1 {
+		t.Errorf("Unexpected expired keys deleted: %v %v", deleted, err)
+	}
+}
+
+func TestIdempotencyKeyReleasedOnPanic(t *testing.T) {
+	a := newTestApp(t)
+	keys := repositories.NewIdempotencyRepository(a.DB, time.Hour, time.Hour)
+
+	var calls int
+	router := gin.New()
+	router.Use(middlewares.Recovery())
+	router.POST("/rents", middlewares.Idempotent(keys), func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			panic("lost connection to the database")
+		}
+		c.JSON(http.StatusOK, models.Response{Status: "Success"})
+	})
+
+	for _, want := range []int{http.StatusInternalServerError, http.StatusOK} {
+		request := httptest.NewRequest("POST", "/rents", strings.NewReader(`{}`))
+		request.Header.Set("Idempotency-Key", "pos-1-0001")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, request)
+		if rr.Code != want {
+			t.Errorf("Unexpected status: got %v want %v: %s", rr.Code, want, rr.Body.String())
+		}
+	}
+}
+
+func TestIdempotencyLeaseRenewed(t *testing.T) {
+	a := newTestApp(t)
+	keys := repositories.NewIdempotencyRepository(a.DB, time.Hour, 100*time.Millisecond)
+
+	var calls int32
+	release := make(chan struct{})
+	router := gin.New()
+	router.POST("/rents", middlewares.Idempotent(keys), func(c *gin.Context) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		c.JSON(http.StatusOK, models.Response{Status: "Success"})
+	})
+	post := func() int {
+		request := httptest.NewRequest("POST", "/rents", strings.NewReader(`{}`))
+		request.Header.Set("Idempotency-Key", "pos-1-0001")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, request)
+		return rr.Code
+	}
+
+	// A request running longer than the lease keeps its key.
+	first := make(chan int)
+	go func() { first <- post() }()
+	time.Sleep(300 * time.Millisecond)
+	if status := post(); status != http.StatusConflict {
+		t.Errorf("Retry of a request still handled answered %v", status)
+	}
+	close(release)
+	if status := <-first; status != http.StatusOK {
+		t.Errorf("Unexpected status: %v", status)
+	}
+	if status := post(); status != http.StatusOK || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("Request handled again: %v %v", status, calls)
+	}
+}
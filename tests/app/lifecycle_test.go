@@ -0,0 +1,108 @@
+package tests_app
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingWorker struct {
+	name   string
+	mu     *sync.Mutex
+	events *[]string
+}
+
+func (w recordingWorker) Name() string {
+	return w.name
+}
+
+func (w recordingWorker) Run(ctx context.Context) error {
+	<-ctx.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	*w.events = append(*w.events, "stopped "+w.name)
+	return ctx.Err()
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	a := newTestApp(t)
+	a.Router.GET("/slow", func(c *gin.Context) {
+		time.Sleep(300 * time.Millisecond)
+		c.String(http.StatusOK, "done")
+	})
+	var mu sync.Mutex
+	var events []string
+	a.AddWorker(recordingWorker{name: "first", mu: &mu, events: &events})
+	a.AddWorker(recordingWorker{name: "second", mu: &mu, events: &events})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- a.Serve(ctx, listener)
+	}()
+
+	response := make(chan string, 1)
+	go func() {
+		res, err := http.Get("http://" + listener.Addr().String() + "/slow")
+		if err != nil {
+			response <- err.Error()
+			return
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		response <- string(body)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	if body := <-response; body != "done" {
+		t.Errorf("In-flight request was not drained: %v", body)
+	}
+	if err = <-served; err != nil {
+		t.Errorf("Unexpected shutdown error: %v", err)
+	}
+	if len(events) != 2 || events[0] != "stopped second" || events[1] != "stopped first" {
+		t.Errorf("Workers stopped in the wrong order: %v", events)
+	}
+	sqlDB, _ := a.DB.DB()
+	if err = sqlDB.Ping(); err == nil {
+		t.Error("Database was not closed")
+	}
+}
+
+func TestForcedShutdown(t *testing.T) {
+	a := newTestApp(t)
+	a.Config.HTTP.ShutdownTimeout = 50 * time.Millisecond
+	a.Router.GET("/slow", func(c *gin.Context) {
+		time.Sleep(time.Second)
+		c.String(http.StatusOK, "done")
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- a.Serve(ctx, listener)
+	}()
+	go func() {
+		_, _ = http.Get("http://" + listener.Addr().String() + "/slow")
+	}()
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	if err = <-served; err == nil {
+		t.Error("Expected an error when requests are cut off")
+	}
+}
@@ -0,0 +1,94 @@
+package tests_app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"github/jorgemvv01/go-api/app"
+	"github/jorgemvv01/go-api/config"
+	"github/jorgemvv01/go-api/logging"
+	"github/jorgemvv01/go-api/middlewares"
+	"github/jorgemvv01/go-api/migrations"
+	"github/jorgemvv01/go-api/storage"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	a := newTestApp(t)
+
+	request := httptest.NewRequest("GET", "/api/movies/999", nil)
+	request.Header.Set(middlewares.RequestIDHeader, "client-id-1")
+	rr := httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, request)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("Expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+	if got := rr.Header().Get(middlewares.RequestIDHeader); got != "client-id-1" {
+		t.Errorf("Expected the request ID to be echoed, got %q", got)
+	}
+	var response map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response["request_id"] != "client-id-1" {
+		t.Errorf("Expected the request ID in the error body, got %v", response)
+	}
+
+	for _, header := range []string{"", "invalid id\n"} {
+		request = httptest.NewRequest("GET", "/healthz", nil)
+		request.Header.Set(middlewares.RequestIDHeader, header)
+		rr = httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, request)
+		if got := rr.Header().Get(middlewares.RequestIDHeader); got == "" || got == header {
+			t.Errorf("Expected a generated request ID for %q, got %q", header, got)
+		}
+	}
+}
+
+func TestRequestLogs(t *testing.T) {
+	cfg := config.Default()
+	cfg.Database.Driver = config.DriverSQLite
+	cfg.Database.DSN = "file:" + filepath.Join(t.TempDir(), "videoclub.db") + "?_foreign_keys=on"
+	cfg.Auth.JWTSecret = "test-secret"
+
+	var logs bytes.Buffer
+	logger := logging.New(config.LogLevelDebug, &logs)
+	db, err := storage.Open(cfg.Database, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := app.NewWithDB(cfg, db, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Close() })
+	if _, err = migrations.Up(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	logs.Reset()
+
+	request := httptest.NewRequest("GET", "/api/movies/999", nil)
+	request.Header.Set(middlewares.RequestIDHeader, "client-id-2")
+	a.Router.ServeHTTP(httptest.NewRecorder(), request)
+
+	messages := map[string]bool{}
+	scanner := bufio.NewScanner(&logs)
+	for scanner.Scan() {
+		var record map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("Invalid log line %q", scanner.Text())
+		}
+		if record["request_id"] != "client-id-2" {
+			t.Errorf("Expected every log line to carry the request ID, got %v", record)
+		}
+		messages[record["msg"].(string)] = true
+	}
+	for _, msg := range []string{"query", "request failed", "request"} {
+		if !messages[msg] {
+			t.Errorf("Expected a %q log line, got %v", msg, messages)
+		}
+	}
+}
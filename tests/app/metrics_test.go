@@ -0,0 +1,52 @@
+package tests_app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	a := newTestApp(t)
+	admin := login(t, a, "admin", "secret")
+
+	steps := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{"POST", "/api/genres/create", `{"name":"Action"}`},
+		{"POST", "/api/movies/create", `{"name":"John Wick: Chapter 4","overview":"John Wick uncovers a path to defeating The High Table.","price":10,"type_id":2,"genre_id":1,"release_date":"2023-03-22"}`},
+		{"POST", "/api/copies/create", `{"movie_id":1,"barcode":"WICK-001"}`},
+		{"POST", "/api/rent/create", `{"user_id":1,"movie_ids":[1],"start_date":"2099-04-07","end_date":"2099-04-12"}`},
+	}
+	for _, step := range steps {
+		if status, response := do(t, a, step.method, step.path, step.body, admin); status != http.StatusOK {
+			t.Fatalf("%s %s: got %v: %v", step.method, step.path, status, response)
+		}
+	}
+	do(t, a, "GET", "/api/movies/999", "", "")
+
+	rr := httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	body := rr.Body.String()
+	for _, want := range []string{
+		`videoclub_http_requests_total{method="POST",route="/api/rent/create",status="200"} 1`,
+		`videoclub_http_requests_total{method="GET",route="/api/movies/:id",status="404"} 1`,
+		`videoclub_http_request_duration_seconds_count{method="POST",route="/api/auth/login"} 1`,
+		`videoclub_db_query_duration_seconds_count{operation="create",table="rents"} 1`,
+		"videoclub_rents_created_total 1",
+		"videoclub_rent_revenue_total 53",
+		`videoclub_movies{type="Regular movies"} 1`,
+		`videoclub_movies{type="New releases"} 0`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in the metrics", want)
+		}
+	}
+}
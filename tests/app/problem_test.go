@@ -0,0 +1,93 @@
+package tests_app
+
+import (
+	"encoding/json"
+	"github/jorgemvv01/go-api/app"
+	"github/jorgemvv01/go-api/middlewares"
+	"github/jorgemvv01/go-api/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func doProblem(t *testing.T, method string, path string, body string, token string, serve func(*httptest.ResponseRecorder, *http.Request)) (int, models.Problem) {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	rr := httptest.NewRecorder()
+	serve(rr, request)
+	if contentType := rr.Header().Get("Content-Type"); !strings.HasPrefix(contentType, middlewares.ProblemContentType) {
+		t.Errorf("%s %s: unexpected content type %q", method, path, contentType)
+	}
+	var problem models.Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("%s %s: invalid problem %q", method, path, rr.Body.String())
+	}
+	return rr.Code, problem
+}
+
+func serveWith(a *app.App) func(*httptest.ResponseRecorder, *http.Request) {
+	return func(rr *httptest.ResponseRecorder, request *http.Request) { a.Router.ServeHTTP(rr, request) }
+}
+
+func TestProblems(t *testing.T) {
+	a := newTestApp(t)
+	admin := login(t, a, "admin", "secret")
+	serve := serveWith(a)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		token  string
+		status int
+		code   string
+	}{
+		{"missing token", "POST", "/api/genres/create", `{"name":"Action"}`, "", http.StatusUnauthorized, "UNAUTHORIZED"},
+		{"invalid token", "POST", "/api/genres/create", `{"name":"Action"}`, "invalid", http.StatusUnauthorized, "INVALID_TOKEN"},
+		{"invalid credentials", "POST", "/api/auth/login", `{"username":"admin","password":"wrong"}`, "", http.StatusUnauthorized, "INVALID_CREDENTIALS"},
+		{"invalid ID", "GET", "/api/movies/abc", "", "", http.StatusBadRequest, "INVALID_ID"},
+		{"movie not found", "GET", "/api/movies/999", "", "", http.StatusNotFound, "MOVIE_NOT_FOUND"},
+		{"malformed body", "POST", "/api/genres/create", `{"name":`, admin, http.StatusBadRequest, "MALFORMED_REQUEST"},
+		{"invalid sort", "GET", "/api/movies?sort=budget", "", "", http.StatusBadRequest, "INVALID_SORT"},
+		{"invalid date range", "POST", "/api/rent/create", `{"user_id":1,"movie_ids":[1],"start_date":"2099-04-12","end_date":"2099-04-07"}`, admin, http.StatusBadRequest, "VALIDATION_FAILED"},
+		{"unknown route", "GET", "/api/unknown", "", "", http.StatusNotFound, "ROUTE_NOT_FOUND"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, problem := doProblem(t, test.method, test.path, test.body, test.token, serve)
+			if status != test.status || problem.Status != test.status || problem.Code != test.code {
+				t.Errorf("Expected %d %s, got %d %+v", test.status, test.code, status, problem)
+			}
+			if problem.Title != http.StatusText(test.status) || problem.RequestID == "" || problem.Instance == "" {
+				t.Errorf("Incomplete problem %+v", problem)
+			}
+		})
+	}
+
+	status, problem := doProblem(t, "POST", "/api/movies/create", `{"name":"","price":"ten"}`, admin, serve)
+	if status != http.StatusBadRequest || problem.Code != "VALIDATION_FAILED" || len(problem.Errors) != 1 || problem.Errors[0].Field != "price" {
+		t.Errorf("Unexpected type error problem %+v", problem)
+	}
+	status, problem = doProblem(t, "POST", "/api/users/create", `{"surname":"Jane","role":"owner"}`, admin, serve)
+	fields := map[string]string{}
+	for _, field := range problem.Errors {
+		fields[field.Field] = field.Code
+	}
+	if status != http.StatusBadRequest || problem.Code != "VALIDATION_FAILED" || fields["lastname"] != "required" || fields["role"] != "oneof" {
+		t.Errorf("Unexpected validation problem %+v", problem)
+	}
+
+	// Database errors are answered without their message.
+	if err := a.DB.Exec("DROP TABLE genres").Error; err != nil {
+		t.Fatal(err)
+	}
+	status, problem = doProblem(t, "GET", "/api/genres/", "", "", serve)
+	if status != http.StatusInternalServerError || problem.Code != "INTERNAL_ERROR" || strings.Contains(problem.Detail, "genres") {
+		t.Errorf("Unexpected internal problem %+v", problem)
+	}
+}
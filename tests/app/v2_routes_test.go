@@ -0,0 +1,76 @@
+package tests_app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestV2Routes(t *testing.T) {
+	a := newTestApp(t)
+	admin := login(t, a, "admin", "secret")
+
+	steps := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"POST", "/api/v2/genres", `{"name":"Action"}`, http.StatusOK},
+		{"PUT", "/api/v2/genres/1", `{"name":"Action & Adventure"}`, http.StatusOK},
+		{"PATCH", "/api/v2/genres/1", `{"name":"Action"}`, http.StatusOK},
+		{"GET", "/api/v2/genres", "", http.StatusOK},
+		{"POST", "/api/v2/types", `{"name":"Classics","base_days":7}`, http.StatusOK},
+		{"GET", "/api/v2/types/4/audits", "", http.StatusOK},
+		{"POST", "/api/v2/movies", `{"name":"John Wick: Chapter 4","overview":"John Wick uncovers a path to defeating The High Table.","price":10,"type_id":2,"genre_id":1,"release_date":"2023-03-22"}`, http.StatusOK},
+		{"PUT", "/api/v2/movies/1", `{"name":"John Wick: Chapter 4","overview":"Wick fights the High Table.","price":12,"type_id":2,"genre_id":1,"release_date":"2023-03-22"}`, http.StatusOK},
+		{"PATCH", "/api/v2/movies/1", `{"price":9.5}`, http.StatusOK},
+		{"POST", "/api/v2/copies", `{"movie_id":1,"barcode":"WICK-001"}`, http.StatusOK},
+		{"GET", "/api/v2/movies/1/copies", "", http.StatusOK},
+		{"POST", "/api/v2/users", `{"surname":"John","lastname":"Doe","username":"jdoe","password":"secret"}`, http.StatusOK},
+		{"PUT", "/api/v2/users/2", `{"surname":"Johnny","lastname":"Doe"}`, http.StatusOK},
+		{"PATCH", "/api/v2/users/2", `{"lastname":"Smith"}`, http.StatusOK},
+		{"POST", "/api/v2/rents", `{"user_id":2,"movie_ids":[1],"start_date":"2023-04-07","end_date":"2099-04-12"}`, http.StatusOK},
+		{"GET", "/api/v2/rents/1", "", http.StatusOK},
+		{"GET", "/api/v2/users/2/rents", "", http.StatusOK},
+		{"POST", "/api/v2/rents/1/return", `{}`, http.StatusOK},
+		{"POST", "/api/v2/rents/1/cancel", "", http.StatusConflict},
+		{"POST", "/api/v2/rents", `{"user_id":2,"movie_ids":[1],"start_date":"2099-05-07","end_date":"2099-05-12"}`, http.StatusOK},
+		{"POST", "/api/v2/rents/2/extend", `{"end_date":"2099-05-14"}`, http.StatusOK},
+		{"POST", "/api/v2/rents/2/cancel", "", http.StatusOK},
+		{"GET", "/api/v2/rents?status=cancelled", "", http.StatusOK},
+		{"DELETE", "/api/v2/copies/1", "", http.StatusOK},
+		{"DELETE", "/api/v2/movies/1", "", http.StatusOK},
+		{"DELETE", "/api/v2/types/4", "", http.StatusOK},
+		{"DELETE", "/api/v2/users/2", "", http.StatusOK},
+		{"DELETE", "/api/v2/genres/1", "", http.StatusOK},
+		{"POST", "/api/v2/movies/create", `{}`, http.StatusNotFound},
+	}
+	for _, step := range steps {
+		if status, response := doWithHeaders(t, a, step.method, step.path, step.body, admin, map[string]string{"If-Match": "*"}); status != step.status {
+			t.Fatalf("%s %s: got %v want %v: %v", step.method, step.path, status, step.status, response)
+		}
+	}
+}
+
+func TestV1Deprecation(t *testing.T) {
+	a := newTestApp(t)
+
+	for path, deprecated := range map[string]bool{
+		"/api/movies":    true,
+		"/api/v2/movies": false,
+		"/healthz":       false,
+	} {
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("GET %s: got %v", path, rr.Code)
+		}
+		if got := rr.Header().Get("Deprecation") == "true"; got != deprecated {
+			t.Errorf("GET %s: expected deprecated %v, got headers %v", path, deprecated, rr.Header())
+		}
+		if deprecated && rr.Header().Get("Link") != `</api/v2>; rel="successor-version"` {
+			t.Errorf("GET %s: unexpected Link header %q", path, rr.Header().Get("Link"))
+		}
+	}
+}
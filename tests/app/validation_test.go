@@ -0,0 +1,68 @@
+package tests_app
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestValidation(t *testing.T) {
+	a := newTestApp(t)
+	admin := login(t, a, "admin", "secret")
+	serve := serveWith(a)
+	yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		fields map[string]string
+	}{
+		{"empty movie", "/api/movies/create", `{}`, map[string]string{
+			"name": "required", "overview": "required", "price": "gt", "type_id": "required", "genre_id": "required", "release_date": "required",
+		}},
+		{"invalid movie", "/api/movies/create", `{"name":"  ","overview":"Overview","price":-1,"type_id":1,"genre_id":1,"release_date":"22/03/2023"}`, map[string]string{
+			"name": "notblank", "price": "gt", "release_date": "date",
+		}},
+		{"long genre name", "/api/genres/create", `{"name":"` + strings.Repeat("a", 101) + `"}`, map[string]string{"name": "max"}},
+		{"invalid type", "/api/types/create", `{"name":"Classics","base_days":-1,"surcharge_percentage":-5}`, map[string]string{
+			"base_days": "gte", "surcharge_percentage": "gte",
+		}},
+		{"invalid copy", "/api/copies/create", `{"barcode":""}`, map[string]string{"movie_id": "required", "barcode": "required"}},
+		{"invalid user", "/api/users/create", `{"surname":"Jane","lastname":"Doe","username":"j doe","password":"123"}`, map[string]string{
+			"username": "excludesall", "password": "min",
+		}},
+		{"rent without movies", "/api/rent/create", `{"user_id":1,"movie_ids":[],"start_date":"2099-04-07","end_date":"2099-04-12"}`, map[string]string{"movie_ids": "min"}},
+		{"rent with duplicated movies", "/api/rent/create", `{"user_id":1,"movie_ids":[1,1],"start_date":"2099-04-07","end_date":"2099-04-12"}`, map[string]string{"movie_ids": "unique"}},
+		{"rent with invalid movie", "/api/rent/create", `{"user_id":1,"movie_ids":[0],"start_date":"2099-04-07","end_date":"2099-04-12"}`, map[string]string{"movie_ids[0]": "gt"}},
+		{"rent in the past", "/api/rent/create", `{"user_id":1,"movie_ids":[1],"start_date":"` + yesterday + `","end_date":"` + yesterday + `"}`, map[string]string{"end_date": "notpast"}},
+		{"rent ending before its start", "/api/rent/create", `{"user_id":1,"movie_ids":[1],"start_date":"2099-04-12","end_date":"2099-04-07"}`, map[string]string{"end_date": "gtedatefield"}},
+		{"rent with invalid dates", "/api/rent/create", `{"user_id":1,"movie_ids":[1],"start_date":"2099-13-01","end_date":"tomorrow"}`, map[string]string{
+			"start_date": "date", "end_date": "date",
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, problem := doProblem(t, "POST", test.path, test.body, admin, serve)
+			if status != http.StatusBadRequest || problem.Code != "VALIDATION_FAILED" {
+				t.Fatalf("Expected a validation problem, got %d %+v", status, problem)
+			}
+			fields := map[string]string{}
+			for _, field := range problem.Errors {
+				fields[field.Field] = field.Code
+				if field.Message == "" {
+					t.Errorf("Missing message for %s", field.Field)
+				}
+			}
+			if len(fields) != len(test.fields) {
+				t.Errorf("Expected fields %v, got %v", test.fields, fields)
+			}
+			for field, code := range test.fields {
+				if fields[field] != code {
+					t.Errorf("Expected %s to fail with %s, got %v", field, code, fields)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package tests_config
+
+import (
+	"github/jorgemvv01/go-api/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfigFile(t, `
+database:
+  dsn: postgresql://file/videoclub
+  max_open_conns: 20
+http:
+  address: ":9000"
+  read_timeout: 5s
+auth:
+  jwt_secret: file-secret
+log_level: warn
+`)
+	t.Setenv("HTTP_ADDRESS", ":9001")
+	t.Setenv("JWT_SECRET", "env-secret")
+
+	cfg, args, err := config.Load([]string{"-config", path, "-jwt-secret", "flag-secret", "-gin-mode", "release", "up"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(args) != 1 || args[0] != "up" {
+		t.Errorf("Unexpected arguments: %v", args)
+	}
+	if cfg.Database.DSN != "postgresql://file/videoclub" || cfg.Database.MaxOpenConns != 20 {
+		t.Errorf("Unexpected database config: %+v", cfg.Database)
+	}
+	if cfg.Database.MaxIdleConns != 5 || cfg.HTTP.WriteTimeout != 15*time.Second {
+		t.Errorf("Defaults not applied: %+v", cfg)
+	}
+	if cfg.HTTP.Address != ":9001" || cfg.HTTP.ReadTimeout != 5*time.Second || cfg.HTTP.GinMode != "release" {
+		t.Errorf("Unexpected http config: %+v", cfg.HTTP)
+	}
+	if cfg.Auth.JWTSecret != "flag-secret" || cfg.LogLevel != "warn" {
+		t.Errorf("Unexpected config: %+v", cfg)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	path := writeConfigFile(t, `
+database:
+  driver: oracle
+http:
+  gin_mode: production
+`)
+	_, _, err := config.Load([]string{"-config", path, "-log-level", "verbose"})
+	if err == nil {
+		t.Fatal("Expected a validation error")
+	}
+	for _, problem := range []string{"database driver", "database dsn", "gin mode", "jwt secret", "log level"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("Error %q does not report the %s", err, problem)
+		}
+	}
+
+	if _, _, err = config.Load([]string{"-http-read-timeout", "soon"}); err == nil || !strings.Contains(err.Error(), "-http-read-timeout") {
+		t.Errorf("Unexpected error for an invalid duration: %v", err)
+	}
+}
@@ -0,0 +1,290 @@
+package tests_controllers
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github/jorgemvv01/go-api/controllers"
+	"github/jorgemvv01/go-api/middlewares"
+	"github/jorgemvv01/go-api/models"
+	"github/jorgemvv01/go-api/repositories"
+	"github/jorgemvv01/go-api/utils"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testTokenService = utils.NewTokenService([]byte("test-secret"), 15*time.Minute, time.Hour)
+
+func createAuthUser(db *gorm.DB, username string, role string) models.User {
+	hash, _ := utils.HashPassword("secret")
+	user := models.User{
+		Surname:      "John",
+		Lastname:     "Doe",
+		Username:     username,
+		PasswordHash: hash,
+		Role:         role,
+	}
+	db.Create(&user)
+	return user
+}
+
+func accessToken(t *testing.T, user models.User) string {
+	tokens, err := testTokenService.Generate(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "Bearer " + tokens.AccessToken
+}
+
+func TestLogin(t *testing.T) {
+	router := gin.Default()
+	db, err := setupDB(models.User{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = dropTable(db, models.User{}); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	user := createAuthUser(db, "jdoe", models.RoleClerk)
+
+	userRepository := repositories.NewUserRepository(db)
+	authController := controllers.NewAuthController(userRepository, testTokenService)
+	router.POST("/auth/login", authController.Login)
+
+	request := httptest.NewRequest("POST", "/auth/login", strings.NewReader(`{"username":"jdoe","password":"wrong"}`))
+	request.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+	if status := rr.Code; status != http.StatusUnauthorized {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusUnauthorized)
+	}
+
+	request = httptest.NewRequest("POST", "/auth/login", strings.NewReader(`{"username":"jdoe","password":"secret"}`))
+	request.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var response struct {
+		Data models.TokenResponse `json:"data"`
+	}
+	if err = json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	claims, err := testTokenService.Parse(response.Data.AccessToken, utils.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserID() != user.ID || claims.Role != models.RoleClerk {
+		t.Errorf("Unexpected token claims: %v", claims)
+	}
+	if _, err = testTokenService.Parse(response.Data.AccessToken, utils.RefreshToken); err == nil {
+		t.Error("Access token accepted as refresh token")
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	router := gin.Default()
+	db, err := setupDB(models.User{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = dropTable(db, models.User{}); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	user := createAuthUser(db, "jdoe", models.RoleCustomer)
+	tokens, err := testTokenService.Generate(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The role change must be reflected in the refreshed tokens.
+	db.Model(&user).Update("role", models.RoleClerk)
+
+	userRepository := repositories.NewUserRepository(db)
+	authController := controllers.NewAuthController(userRepository, testTokenService)
+	router.POST("/auth/refresh", authController.Refresh)
+
+	request := httptest.NewRequest("POST", "/auth/refresh", strings.NewReader(`{"refresh_token":"`+tokens.AccessToken+`"}`))
+	request.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+	if status := rr.Code; status != http.StatusUnauthorized {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusUnauthorized)
+	}
+
+	request = httptest.NewRequest("POST", "/auth/refresh", strings.NewReader(`{"refresh_token":"`+tokens.RefreshToken+`"}`))
+	request.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var response struct {
+		Data models.TokenResponse `json:"data"`
+	}
+	if err = json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	claims, err := testTokenService.Parse(response.Data.AccessToken, utils.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Role != models.RoleClerk {
+		t.Errorf("Unexpected role: got %v want %v", claims.Role, models.RoleClerk)
+	}
+}
+
+func TestUserRoutesRoles(t *testing.T) {
+	router := gin.Default()
+	db, err := setupDB(models.User{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = dropTable(db, models.User{}); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	admin := createAuthUser(db, "admin", models.RoleAdmin)
+	clerk := createAuthUser(db, "clerk", models.RoleClerk)
+	customer := createAuthUser(db, "customer", models.RoleCustomer)
+
+	userRepository := repositories.NewUserRepository(db)
+	userController := controllers.NewUserController(userRepository)
+	userRouter := router.Group("/users", middlewares.Authenticate(testTokenService))
+	userRouter.GET("/:id", userController.GetByID)
+	userRouter.POST("/create", middlewares.RequireRoles(models.RoleClerk, models.RoleAdmin), userController.Create)
+	userRouter.PUT("/update/:id", middlewares.RequireRoles(models.RoleClerk, models.RoleAdmin), userController.Update)
+	userRouter.PATCH("/:id", middlewares.RequireRoles(models.RoleClerk, models.RoleAdmin), userController.Patch)
+	userRouter.DELETE("/delete/:id", middlewares.RequireRoles(models.RoleAdmin), userController.Delete)
+
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		body          string
+		authorization string
+		status        int
+	}{
+		{"missing token", "GET", "/users/3", "", "", http.StatusUnauthorized},
+		{"invalid token", "GET", "/users/3", "", "Bearer invalid", http.StatusUnauthorized},
+		{"customer own user", "GET", "/users/3", "", accessToken(t, customer), http.StatusOK},
+		{"customer other user", "GET", "/users/2", "", accessToken(t, customer), http.StatusForbidden},
+		{"customer create", "POST", "/users/create", `{"surname":"Jane","lastname":"Doe"}`, accessToken(t, customer), http.StatusForbidden},
+		{"clerk create admin", "POST", "/users/create", `{"surname":"Jane","lastname":"Doe","role":"admin"}`, accessToken(t, clerk), http.StatusForbidden},
+		{"clerk create customer", "POST", "/users/create", `{"surname":"Jane","lastname":"Doe","username":"jane","password":"secret"}`, accessToken(t, clerk), http.StatusOK},
+		{"duplicated username", "POST", "/users/create", `{"surname":"Jane","lastname":"Doe","username":"jane"}`, accessToken(t, admin), http.StatusConflict},
+		{"clerk reset admin password", "PUT", "/users/update/1", `{"surname":"x","lastname":"y","password":"newpass"}`, accessToken(t, clerk), http.StatusForbidden},
+		{"clerk patch admin", "PATCH", "/users/1", `{"lastname":"y"}`, accessToken(t, clerk), http.StatusForbidden},
+		{"clerk update clerk", "PUT", "/users/update/2", `{"surname":"x","lastname":"y"}`, accessToken(t, clerk), http.StatusForbidden},
+		{"clerk update customer", "PUT", "/users/update/3", `{"surname":"John","lastname":"Smith"}`, accessToken(t, clerk), http.StatusOK},
+		{"clerk patch customer", "PATCH", "/users/3", `{"lastname":"Doe"}`, accessToken(t, clerk), http.StatusOK},
+		{"clerk update missing user", "PUT", "/users/update/9", `{"surname":"x","lastname":"y"}`, accessToken(t, clerk), http.StatusNotFound},
+		{"admin update clerk", "PUT", "/users/update/2", `{"surname":"Jim","lastname":"Doe"}`, accessToken(t, admin), http.StatusOK},
+		{"clerk delete", "DELETE", "/users/delete/3", "", accessToken(t, clerk), http.StatusForbidden},
+		{"admin delete", "DELETE", "/users/delete/3", "", accessToken(t, admin), http.StatusOK},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		request.Header.Set("Content-Type", "application/json")
+		if test.authorization != "" {
+			request.Header.Set("Authorization", test.authorization)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, request)
+		if status := rr.Code; status != test.status {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", test.name, status, test.status)
+		}
+	}
+
+	var stored models.User
+	db.First(&stored, admin.ID)
+	if stored.Surname != admin.Surname || !utils.CheckPassword(stored.PasswordHash, "secret") {
+		t.Errorf("Administrator modified by a clerk: %v", stored)
+	}
+
+	var user models.User
+	db.Where("username = ?", "jane").First(&user)
+	if user.Role != models.RoleCustomer || !utils.CheckPassword(user.PasswordHash, "secret") {
+		t.Errorf("Unexpected user credentials: %v", user)
+	}
+}
+
+func TestCustomerRents(t *testing.T) {
+	router := gin.Default()
+	db, err := setupDB(models.Type{}, models.Genre{}, models.Movie{}, models.User{}, models.Copy{}, models.Rent{}, models.MovieRent{}, models.RentExtension{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = dropTable(db, models.Type{}, models.Genre{}, models.Movie{}, models.User{}, models.Copy{}, models.Rent{}, models.MovieRent{}, models.RentExtension{}); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	createRentFixtures(db)
+	customer := models.User{Model: gorm.Model{ID: 1}, Role: models.RoleCustomer}
+	clerk := createAuthUser(db, "clerk", models.RoleClerk)
+
+	rentRepository := repositories.NewRentRepository(db)
+	rentController := controllers.NewRentController(rentRepository)
+	rentRouter := router.Group("/rent", middlewares.Authenticate(testTokenService))
+	rentRouter.GET("", rentController.GetAll)
+	rentRouter.GET("/:id", rentController.GetByID)
+	rentRouter.POST("/create", rentController.Create)
+
+	request := httptest.NewRequest("GET", "/rent", nil)
+	request.Header.Set("Authorization", accessToken(t, customer))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	var response struct {
+		Data []models.RentResponse `json:"data"`
+	}
+	if err = json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Data) != 1 || response.Data[0].UserID != 1 {
+		t.Errorf("Unexpected rents: %v", response.Data)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		user   models.User
+		status int
+	}{
+		{"customer own rent", "GET", "/rent/1", "", customer, http.StatusOK},
+		{"customer other rent", "GET", "/rent/2", "", customer, http.StatusForbidden},
+		{"clerk other rent", "GET", "/rent/2", "", clerk, http.StatusOK},
+		{"customer rent for other", "POST", "/rent/create", `{"user_id":2,"movie_ids":[2],"start_date":"2099-05-01","end_date":"2099-05-02"}`, customer, http.StatusForbidden},
+		{"clerk rent for customer", "POST", "/rent/create", `{"user_id":2,"movie_ids":[2],"start_date":"2099-05-01","end_date":"2099-05-02"}`, clerk, http.StatusOK},
+	}
+	for _, test := range tests {
+		request = httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("Authorization", accessToken(t, test.user))
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, request)
+		if status := rr.Code; status != test.status {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", test.name, status, test.status)
+		}
+	}
+}
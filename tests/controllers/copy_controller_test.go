@@ -0,0 +1,214 @@
+package tests_controllers
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github/jorgemvv01/go-api/controllers"
+	"github/jorgemvv01/go-api/models"
+	"github/jorgemvv01/go-api/repositories"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCreateCopy(t *testing.T) {
+	router := gin.Default()
+	db, err := setupDB(models.Type{}, models.Genre{}, models.Movie{}, models.Copy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = dropTable(db, models.Type{}, models.Genre{}, models.Movie{}, models.Copy{}); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	movieType := models.Type{
+		Name: "New releases",
+	}
+	genre := models.Genre{
+		Name: "Action",
+	}
+	movie := models.Movie{
+		Name:        "John Wick: Chapter 4",
+		Overview:    "With the price on his head ever increasing, John Wick uncovers a path to defeating The High Table.",
+		Price:       10,
+		TypeID:      1,
+		GenreID:     1,
+		ReleaseDate: "2023-03-22",
+	}
+	db.Create(&movieType)
+	db.Create(&genre)
+	db.Create(&movie)
+
+	copyRepository := repositories.NewCopyRepository(db)
+	copyController := controllers.NewCopyController(copyRepository)
+	router.POST("/copies/create", copyController.Create)
+
+	requestBody := `{"movie_id":1,"barcode":"WICK-001"}`
+	request := httptest.NewRequest("POST", "/copies/create", strings.NewReader(requestBody))
+	request.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var movieCopy models.Copy
+	db.Last(&movieCopy)
+	if movieCopy.Barcode != "WICK-001" || movieCopy.MovieID != 1 {
+		t.Errorf("Unexpected copy: %v", movieCopy)
+	}
+
+	request = httptest.NewRequest("POST", "/copies/create", strings.NewReader(requestBody))
+	request.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+
+	if status := rr.Code; status != http.StatusConflict {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusConflict)
+	}
+
+	// Deleted copies are restored when added again, only to their movie.
+	db.Create(&models.Movie{Name: "Rambo", Overview: "Rambo", Price: 10, TypeID: 1, GenreID: 1, ReleaseDate: "2008-01-25"})
+	db.Delete(&movieCopy)
+	for _, test := range []struct {
+		body   string
+		status int
+	}{
+		{`{"movie_id":2,"barcode":"WICK-001"}`, http.StatusConflict},
+		{requestBody, http.StatusOK},
+	} {
+		request = httptest.NewRequest("POST", "/copies/create", strings.NewReader(test.body))
+		request.Header.Set("Content-Type", "application/json")
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, request)
+		if status := rr.Code; status != test.status {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", test.body, status, test.status)
+		}
+	}
+	var restored models.Copy
+	db.Find(&restored, movieCopy.ID)
+	if restored.ID != movieCopy.ID {
+		t.Errorf("Copy was not restored")
+	}
+}
+
+func TestGetCopiesByMovieID(t *testing.T) {
+	router := gin.Default()
+	db, err := setupDB(models.Type{}, models.Genre{}, models.Movie{}, models.Copy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = dropTable(db, models.Type{}, models.Genre{}, models.Movie{}, models.Copy{}); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	movieType := models.Type{
+		Name: "New releases",
+	}
+	genre := models.Genre{
+		Name: "Action",
+	}
+	movie := models.Movie{
+		Name:        "John Wick: Chapter 4",
+		Overview:    "With the price on his head ever increasing, John Wick uncovers a path to defeating The High Table.",
+		Price:       10,
+		TypeID:      1,
+		GenreID:     1,
+		ReleaseDate: "2023-03-22",
+	}
+	db.Create(&movieType)
+	db.Create(&genre)
+	db.Create(&movie)
+	db.Create(&models.Copy{MovieID: 1, Barcode: "WICK-001"})
+	db.Create(&models.Copy{MovieID: 1, Barcode: "WICK-002"})
+
+	copyRepository := repositories.NewCopyRepository(db)
+	copyController := controllers.NewCopyController(copyRepository)
+
+	request := httptest.NewRequest("GET", "/movies/1/copies", nil)
+	request.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	router.GET("/movies/:id/copies", copyController.GetByMovieID)
+	router.ServeHTTP(rr, request)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var responseBody models.Response
+	if err = json.Unmarshal(rr.Body.Bytes(), &responseBody); err != nil {
+		t.Error(err)
+	}
+	if responseBody.Data == nil {
+		t.Error(responseBody.Message)
+	}
+
+	data, ok := responseBody.Data.([]interface{})
+	if !ok {
+		t.Errorf("Bad data response structure")
+	}
+	if len(data) != 2 {
+		t.Errorf("Unexpected number of copies: %v", len(data))
+	}
+}
+
+func TestDeleteCopy(t *testing.T) {
+	router := gin.Default()
+	db, err := setupDB(models.Type{}, models.Genre{}, models.Movie{}, models.Copy{}, models.User{}, models.Rent{}, models.MovieRent{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = dropTable(db, models.Type{}, models.Genre{}, models.Movie{}, models.Copy{}, models.User{}, models.Rent{}, models.MovieRent{}); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	db.Create(&models.Copy{MovieID: 1, Barcode: "WICK-001"})
+	db.Create(&models.Copy{MovieID: 1, Barcode: "WICK-002"})
+	db.Create(&models.Copy{MovieID: 1, Barcode: "WICK-003"})
+	// Copy 1 was returned, copy 2 is overdue and copy 3 is booked.
+	db.Create(&models.Rent{UserID: 1, StartDate: "2020-01-01", EndDate: "2020-01-03", MovieRents: []models.MovieRent{
+		{MovieID: 1, CopyID: 1, ReturnDate: "2020-01-02"},
+		{MovieID: 1, CopyID: 2},
+	}})
+	db.Create(&models.Rent{UserID: 1, StartDate: "2099-01-01", EndDate: "2099-01-03", MovieRents: []models.MovieRent{
+		{MovieID: 1, CopyID: 3},
+	}})
+
+	copyRepository := repositories.NewCopyRepository(db)
+	copyController := controllers.NewCopyController(copyRepository)
+
+	request := httptest.NewRequest("DELETE", "/copies/delete/1", nil)
+	request.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	router.DELETE("/copies/delete/:id", copyController.Delete)
+	router.ServeHTTP(rr, request)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var movieCopy models.Copy
+	db.Find(&movieCopy, 1)
+	if movieCopy.ID != 0 {
+		t.Errorf("Copy was not deleted")
+	}
+
+	for _, path := range []string{"/copies/delete/2", "/copies/delete/3"} {
+		request = httptest.NewRequest("DELETE", path, nil)
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, request)
+		if status := rr.Code; status != http.StatusConflict {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", path, status, http.StatusConflict)
+		}
+	}
+}
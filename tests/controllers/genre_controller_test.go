@@ -203,6 +203,65 @@ func TestUpdateGenre(t *testing.T) {
 	}
 }
 
+func TestPatchGenre(t *testing.T) {
+	router := gin.Default()
+	db, err := setupDB(models.Genre{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = dropTable(db, models.Genre{}); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	genre := models.Genre{
+		Name: "Action",
+	}
+
+	db.Create(&genre)
+
+	genreRepository := repositories.NewGenreRepository(db)
+	genreController := controllers.NewGenreController(genreRepository)
+
+	requestBody := `{"name":"Comedy"}`
+	request := httptest.NewRequest("PATCH", "/genres/1", strings.NewReader(requestBody))
+	request.Header.Set("Content-Type", "application/merge-patch+json")
+
+	rr := httptest.NewRecorder()
+
+	router.PATCH("/genres/:id", genreController.Patch)
+	router.ServeHTTP(rr, request)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var responseBody models.Response
+	if err = json.Unmarshal(rr.Body.Bytes(), &responseBody); err != nil {
+		t.Error(err)
+	}
+
+	data, ok := responseBody.Data.(map[string]interface{})
+
+	if !ok {
+		t.Fatalf("Bad data response structure")
+	}
+
+	if data["name"] != "Comedy" {
+		t.Errorf("Genre name does not match")
+	}
+
+	request = httptest.NewRequest("PATCH", "/genres/2", strings.NewReader(requestBody))
+	request.Header.Set("Content-Type", "application/merge-patch+json")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+	}
+}
+
 func TestDeleteGenre(t *testing.T) {
 	router := gin.Default()
 	db, err := setupDB(models.Genre{})
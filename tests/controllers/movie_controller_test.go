@@ -1,11 +1,14 @@
 package tests_controllers
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github/jorgemvv01/go-api/controllers"
 	"github/jorgemvv01/go-api/models"
 	"github/jorgemvv01/go-api/repositories"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -303,6 +306,88 @@ func TestUpdateMovie(t *testing.T) {
 	}
 }
 
+func TestPatchMovie(t *testing.T) {
+	router := gin.Default()
+	db, err := setupDB(models.Type{}, models.Genre{}, models.Movie{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = dropTable(db, models.Type{}, models.Genre{}, models.Movie{}); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	movieType := models.Type{
+		Name: "New releases",
+	}
+	genre := models.Genre{
+		Name: "Science Fiction",
+	}
+	overview := "Set more than a decade after the events of the first film, learn the story of the Sully family (Jake, Neytiri, and their kids), the trouble that follows them, the lengths they go to keep each other safe, the battles they fight to stay alive, and the tragedies they endure."
+	movie := models.Movie{
+		Name:        "Avatar: The Way of Water",
+		Overview:    overview,
+		Price:       10.25,
+		TypeID:      1,
+		GenreID:     1,
+		ReleaseDate: "2022-12-15",
+	}
+	db.Create(&movieType)
+	db.Create(&genre)
+	db.Create(&movie)
+
+	movieRepository := repositories.NewMovieRepository(db)
+	movieController := controllers.NewMovieController(movieRepository)
+	router.PATCH("/movies/:id", movieController.Patch)
+
+	tests := []struct {
+		body        string
+		contentType string
+		status      int
+		code        string
+	}{
+		{`{"price":8.5}`, "application/merge-patch+json", http.StatusOK, ""},
+		{`{"price":0}`, "application/merge-patch+json", http.StatusBadRequest, "VALIDATION_FAILED"},
+		{`{"overview":null}`, "application/merge-patch+json", http.StatusBadRequest, "VALIDATION_FAILED"},
+		{`{"type_id":7}`, "application/merge-patch+json", http.StatusNotFound, "TYPE_NOT_FOUND"},
+		{`[{"op":"replace","path":"/price","value":9}]`, "application/merge-patch+json", http.StatusBadRequest, "MALFORMED_REQUEST"},
+		{`[{"op":"replace","path":"/price","value":9}]`, "application/json-patch+json", http.StatusUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE"},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest("PATCH", "/movies/1", strings.NewReader(test.body))
+		request.Header.Set("Content-Type", test.contentType)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, request)
+
+		if status := rr.Code; status != test.status {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", test.body, status, test.status)
+		}
+		if test.code == "" {
+			continue
+		}
+		var problem models.Problem
+		if err = json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+			t.Error(err)
+		}
+		if problem.Code != test.code {
+			t.Errorf("%s: got code %q want %q", test.body, problem.Code, test.code)
+		}
+	}
+
+	var patched models.Movie
+	db.Preload("Type").First(&patched, movie.ID)
+	if patched.Price != 8.5 {
+		t.Errorf("Price was not patched: %v", patched.Price)
+	}
+	if patched.Overview != overview || patched.Name != movie.Name || patched.ReleaseDate != movie.ReleaseDate {
+		t.Errorf("Fields missing from the patch were changed: %+v", patched)
+	}
+	if patched.TypeID != 1 {
+		t.Errorf("Type ID was changed by a failed patch: %v", patched.TypeID)
+	}
+}
+
 func TestDeleteMovie(t *testing.T) {
 	router := gin.Default()
 	db, err := setupDB(models.Type{}, models.Genre{}, models.Movie{})
@@ -357,3 +442,221 @@ func TestDeleteMovie(t *testing.T) {
 	}
 
 }
+
+func TestGetAllMoviesPagination(t *testing.T) {
+	router := gin.Default()
+	db, err := setupDB(models.Type{}, models.Genre{}, models.Movie{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = dropTable(db, models.Type{}, models.Genre{}, models.Movie{}); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	movieType := models.Type{
+		Name: "New releases",
+	}
+	genre1 := models.Genre{
+		Name: "Science Fiction",
+	}
+	genre2 := models.Genre{
+		Name: "Action",
+	}
+	db.Create(&movieType)
+	db.Create(&genre1)
+	db.Create(&genre2)
+	for i := 1; i <= 5; i++ {
+		db.Create(&models.Movie{
+			Name:        fmt.Sprintf("Movie %d", i),
+			Overview:    "Overview",
+			Price:       float64(i),
+			TypeID:      1,
+			GenreID:     uint(i%2 + 1),
+			ReleaseDate: fmt.Sprintf("202%d-01-01", i),
+		})
+	}
+
+	movieRepository := repositories.NewMovieRepository(db)
+	movieController := controllers.NewMovieController(movieRepository)
+	router.GET("/movies", movieController.GetAll)
+
+	request := httptest.NewRequest("GET", "/movies?genre_id=2&sort=-price&page=1&page_size=2", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var responseBody models.Response
+	if err = json.Unmarshal(rr.Body.Bytes(), &responseBody); err != nil {
+		t.Error(err)
+	}
+	data, ok := responseBody.Data.([]interface{})
+	if !ok || len(data) != 2 {
+		t.Fatalf("Unexpected movies: %v", responseBody.Data)
+	}
+	if movie := data[0].(map[string]interface{}); movie["name"] != "Movie 5" {
+		t.Errorf("Movies are not sorted by price: %v", movie["name"])
+	}
+	if responseBody.Pagination == nil || responseBody.Pagination.Total != 3 || responseBody.Pagination.TotalPages != 2 {
+		t.Fatalf("Unexpected pagination: %v", responseBody.Pagination)
+	}
+	if responseBody.Pagination.Next != "/movies?genre_id=2&page=2&page_size=2&sort=-price" {
+		t.Errorf("Unexpected next page link: %v", responseBody.Pagination.Next)
+	}
+	if responseBody.Pagination.Prev != "" {
+		t.Errorf("Unexpected previous page link: %v", responseBody.Pagination.Prev)
+	}
+
+	request = httptest.NewRequest("GET", "/movies?release_year=2023&min_price=2&max_price=4", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+
+	responseBody = models.Response{}
+	if err = json.Unmarshal(rr.Body.Bytes(), &responseBody); err != nil {
+		t.Error(err)
+	}
+	if data, ok = responseBody.Data.([]interface{}); !ok || len(data) != 1 {
+		t.Errorf("Unexpected movies: %v", responseBody.Data)
+	}
+
+	for _, path := range []string{"/movies?sort=overview", "/movies?page=100001", "/movies?page=9223372036854775807"} {
+		request = httptest.NewRequest("GET", path, nil)
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, request)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", path, status, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestSearchMovies(t *testing.T) {
+	router := gin.Default()
+	db, err := setupDB(models.Type{}, models.Genre{}, models.Movie{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = dropTable(db, models.Type{}, models.Genre{}, models.Movie{}); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	movieType := models.Type{
+		Name: "New releases",
+	}
+	genre := models.Genre{
+		Name: "Action",
+	}
+	movie1 := models.Movie{
+		Name:        "Nobody",
+		Overview:    "A bystander who intervenes to help a woman being harassed by a group of men becomes the target of a vengeful drug lord, much like John Wick.",
+		Price:       9,
+		TypeID:      1,
+		GenreID:     1,
+		ReleaseDate: "2021-03-18",
+	}
+	movie2 := models.Movie{
+		Name:        "John Wick: Chapter 4",
+		Overview:    "With the price on his head ever increasing, John Wick uncovers a path to defeating The High Table.",
+		Price:       10,
+		TypeID:      1,
+		GenreID:     1,
+		ReleaseDate: "2023-03-22",
+	}
+	movie3 := models.Movie{
+		Name:        "Rambo",
+		Overview:    "Rambo sets aside his peaceful existence to take action.",
+		Price:       8,
+		TypeID:      1,
+		GenreID:     1,
+		ReleaseDate: "2008-01-25",
+	}
+	db.Create(&movieType)
+	db.Create(&genre)
+	db.Create(&movie1)
+	db.Create(&movie2)
+	db.Create(&movie3)
+	db.Create(&models.Movie{
+		Name:        "Scream",
+		Overview:    `Ghostface is back <script>alert("scream")</script> in town.`,
+		Price:       8,
+		TypeID:      1,
+		GenreID:     1,
+		ReleaseDate: "2022-01-14",
+	})
+
+	movieRepository := repositories.NewMovieRepository(db)
+	movieController := controllers.NewMovieController(movieRepository)
+	router.GET("/movies/search", movieController.Search)
+
+	request := httptest.NewRequest("GET", "/movies/search?q=john+WICK", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var responseBody models.Response
+	if err = json.Unmarshal(rr.Body.Bytes(), &responseBody); err != nil {
+		t.Error(err)
+	}
+	data, ok := responseBody.Data.([]interface{})
+	if !ok || len(data) != 2 {
+		t.Fatalf("Unexpected movies: %v", responseBody.Data)
+	}
+	first := data[0].(map[string]interface{})
+	if first["name"] != "John Wick: Chapter 4" {
+		t.Errorf("Movies are not ranked: %v", first["name"])
+	}
+	if first["snippet"] != "...head ever increasing, <mark>John</mark> <mark>Wick</mark> uncovers a path to defeating The High Table." {
+		t.Errorf("Unexpected snippet: %v", first["snippet"])
+	}
+	if second := data[1].(map[string]interface{}); !strings.HasSuffix(second["snippet"].(string), "<mark>John</mark> <mark>Wick.</mark>") {
+		t.Errorf("Unexpected snippet: %v", second["snippet"])
+	}
+
+	// Markup of the overview is escaped, only the highlight is HTML.
+	request = httptest.NewRequest("GET", "/movies/search?q=scream", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+
+	responseBody = models.Response{}
+	if err = json.Unmarshal(rr.Body.Bytes(), &responseBody); err != nil {
+		t.Error(err)
+	}
+	data, ok = responseBody.Data.([]interface{})
+	if !ok || len(data) != 1 {
+		t.Fatalf("Unexpected movies: %v", responseBody.Data)
+	}
+	if snippet := data[0].(map[string]interface{})["snippet"]; snippet != "Ghostface is back <mark>&lt;script&gt;alert(&#34;scream&#34;)&lt;/script&gt;</mark> in town." {
+		t.Errorf("Overview markup not escaped: %v", snippet)
+	}
+
+	request = httptest.NewRequest("GET", "/movies/search", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+
+	query := &models.MovieSearchQuery{PageQuery: models.PageQuery{Page: 2, PageSize: 1}, Q: "john"}
+	movies, total, err := movieRepository.Search(context.Background(), query)
+	if err != nil || total != 2 || len(*movies) != 1 || (*movies)[0].Name != "Nobody" {
+		t.Errorf("Unexpected movies on the second page: %v %v %v", movies, total, err)
+	}
+
+	for _, page := range []int{2, math.MaxInt} {
+		query := &models.MovieSearchQuery{PageQuery: models.PageQuery{Page: page, PageSize: 20}, Q: "john"}
+		movies, total, err := movieRepository.Search(context.Background(), query)
+		if err != nil || total != 2 || len(*movies) != 0 {
+			t.Errorf("Unexpected movies on page %d: %v %v %v", page, movies, total, err)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package tests_controllers
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github/jorgemvv01/go-api/controllers"
+	"github/jorgemvv01/go-api/models"
+	"github/jorgemvv01/go-api/repositories"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func createCatalog(db *gorm.DB, movies int) {
+	var movieTypes []models.Type
+	var genres []models.Genre
+	for i := 1; i <= 3; i++ {
+		movieTypes = append(movieTypes, models.Type{Name: fmt.Sprintf("Type %d", i)})
+		genres = append(genres, models.Genre{Name: fmt.Sprintf("Genre %d", i)})
+	}
+	db.Create(&movieTypes)
+	db.Create(&genres)
+
+	var catalog []models.Movie
+	var copies []models.Copy
+	for i := 1; i <= movies; i++ {
+		catalog = append(catalog, models.Movie{
+			Name:        fmt.Sprintf("Movie %d", i),
+			Overview:    "Overview",
+			Price:       10,
+			TypeID:      uint(i%3 + 1),
+			GenreID:     uint(i%3 + 1),
+			ReleaseDate: "2023-01-01",
+		})
+		copies = append(copies, models.Copy{MovieID: uint(i), Barcode: fmt.Sprintf("MOVIE-%d", i)})
+	}
+	db.CreateInBatches(&catalog, 100)
+	db.CreateInBatches(&copies, 100)
+}
+
+func getAllMoviesQueries(t testing.TB, movies int) int64 {
+	router := gin.New()
+	db, err := setupDB(models.Type{}, models.Genre{}, models.Movie{}, models.Copy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = dropTable(db, models.Type{}, models.Genre{}, models.Movie{}, models.Copy{}); err != nil {
+			t.Error(err)
+		}
+	}()
+	createCatalog(db, movies)
+
+	queries, err := countQueries(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	movieRepository := repositories.NewMovieRepository(db)
+	movieController := controllers.NewMovieController(movieRepository)
+	router.GET("/movies", movieController.GetAll)
+
+	request := httptest.NewRequest("GET", "/movies?page_size=100", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	return atomic.LoadInt64(queries)
+}
+
+func createRentQueries(t testing.TB, movies int) int64 {
+	router := gin.New()
+	db, err := setupDB(models.Type{}, models.Genre{}, models.Movie{}, models.Copy{}, models.User{}, models.Rent{}, models.MovieRent{}, models.RentExtension{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = dropTable(db, models.Type{}, models.Genre{}, models.Movie{}, models.Copy{}, models.User{}, models.Rent{}, models.MovieRent{}, models.RentExtension{}); err != nil {
+			t.Error(err)
+		}
+	}()
+	createCatalog(db, movies)
+	db.Create(&models.User{Surname: "John", Lastname: "Doe"})
+
+	queries, err := countQueries(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rentRepository := repositories.NewRentRepository(db)
+	rentController := controllers.NewRentController(rentRepository)
+	router.POST("/rent/create", rentController.Create)
+
+	var movieIDs []string
+	for i := 1; i <= movies; i++ {
+		movieIDs = append(movieIDs, fmt.Sprint(i))
+	}
+	requestBody := `{"user_id":1,"movie_ids":[` + strings.Join(movieIDs, ",") + `],"start_date":"2099-04-07","end_date":"2099-04-10"}`
+	request := httptest.NewRequest("POST", "/rent/create", strings.NewReader(requestBody))
+	request.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	return atomic.LoadInt64(queries)
+}
+
+func TestGetAllMoviesQueryCount(t *testing.T) {
+	small := getAllMoviesQueries(t, 5)
+	large := getAllMoviesQueries(t, 100)
+	if small != large {
+		t.Errorf("Query count grows with the catalog: %v queries for 5 movies, %v for 100", small, large)
+	}
+}
+
+func TestCreateRentQueryCount(t *testing.T) {
+	small := createRentQueries(t, 2)
+	large := createRentQueries(t, 50)
+	if small != large {
+		t.Errorf("Query count grows with the movies rented: %v queries for 2 movies, %v for 50", small, large)
+	}
+}
+
+func BenchmarkGetAllMovies(b *testing.B) {
+	for _, movies := range []int{100, 1000, 5000} {
+		b.Run(fmt.Sprintf("movies=%d", movies), func(b *testing.B) {
+			router := gin.New()
+			db, err := setupDB(models.Type{}, models.Genre{}, models.Movie{}, models.Copy{})
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer func() {
+				if err = dropTable(db, models.Type{}, models.Genre{}, models.Movie{}, models.Copy{}); err != nil {
+					b.Error(err)
+				}
+			}()
+			createCatalog(db, movies)
+
+			queries, err := countQueries(db)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			movieRepository := repositories.NewMovieRepository(db)
+			movieController := controllers.NewMovieController(movieRepository)
+			router.GET("/movies", movieController.GetAll)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				request := httptest.NewRequest("GET", "/movies?page_size=100", nil)
+				rr := httptest.NewRecorder()
+				router.ServeHTTP(rr, request)
+				if status := rr.Code; status != http.StatusOK {
+					b.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+				}
+			}
+			b.ReportMetric(float64(atomic.LoadInt64(queries))/float64(b.N), "queries/op")
+		})
+	}
+}
@@ -1,25 +1,33 @@
 package tests_controllers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github/jorgemvv01/go-api/controllers"
 	"github/jorgemvv01/go-api/models"
 	"github/jorgemvv01/go-api/repositories"
+	"github/jorgemvv01/go-api/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestCreateRent(t *testing.T) {
 	router := gin.Default()
-	db, err := setupDB(models.Type{}, models.Genre{}, models.Movie{}, models.User{}, models.Rent{}, models.MovieRent{})
+	db, err := setupDB(models.Type{}, models.Genre{}, models.Movie{}, models.User{}, models.Copy{}, models.Rent{}, models.MovieRent{}, models.RentExtension{})
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer func() {
-		if err = dropTable(db, models.Type{}, models.Genre{}, models.Movie{}, models.User{}, models.Rent{}, models.MovieRent{}); err != nil {
+		if err = dropTable(db, models.Type{}, models.Genre{}, models.Movie{}, models.User{}, models.Copy{}, models.Rent{}, models.MovieRent{}, models.RentExtension{}); err != nil {
 			t.Error(err)
 		}
 	}()
@@ -29,9 +37,17 @@ func TestCreateRent(t *testing.T) {
 	}
 	movieType2 := models.Type{
 		Name: "Regular movies",
+		PricingRule: models.PricingRule{
+			BaseDays:            3,
+			SurchargePercentage: 15,
+		},
 	}
 	movieType3 := models.Type{
 		Name: "Old movies",
+		PricingRule: models.PricingRule{
+			BaseDays:            5,
+			SurchargePercentage: 10,
+		},
 	}
 	genre1 := models.Genre{
 		Name: "Science Fiction",
@@ -68,6 +84,8 @@ func TestCreateRent(t *testing.T) {
 	db.Create(&movie1)
 	db.Create(&movie2)
 	db.Create(&user)
+	db.Create(&models.Copy{MovieID: 1, Barcode: "AVATAR-001"})
+	db.Create(&models.Copy{MovieID: 2, Barcode: "RAMBO-001"})
 
 	rentRepository := repositories.NewRentRepository(db)
 	rentController := controllers.NewRentController(rentRepository)
@@ -77,8 +95,8 @@ func TestCreateRent(t *testing.T) {
 	  "movie_ids": [
 		1,2
 	  ],
-	  "start_date": "2023-04-07",
-      "end_date": "2023-04-15"
+	  "start_date": "2099-04-07",
+      "end_date": "2099-04-15"
 	}`
 	request := httptest.NewRequest("POST", "/rent/create", strings.NewReader(requestBody))
 
@@ -110,10 +128,739 @@ func TestCreateRent(t *testing.T) {
 	if data["total"] != 171.174 {
 		t.Errorf("Total does not match")
 	}
-	if data["start_date"] != "2023-04-07" {
+	if data["start_date"] != "2099-04-07" {
 		t.Errorf("Start date does not match")
 	}
-	if data["end_date"] != "2023-04-15" {
+	if data["end_date"] != "2099-04-15" {
 		t.Errorf("End date does not match")
 	}
 }
+
+func TestReturnRent(t *testing.T) {
+	router := gin.Default()
+	db, err := setupDB(models.Type{}, models.Genre{}, models.Movie{}, models.User{}, models.Copy{}, models.Rent{}, models.MovieRent{}, models.RentExtension{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = dropTable(db, models.Type{}, models.Genre{}, models.Movie{}, models.User{}, models.Copy{}, models.Rent{}, models.MovieRent{}, models.RentExtension{}); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	movieType1 := models.Type{
+		Name: "New releases",
+	}
+	movieType2 := models.Type{
+		Name: "Regular movies",
+		PricingRule: models.PricingRule{
+			BaseDays:            3,
+			SurchargePercentage: 15,
+		},
+	}
+	movieType3 := models.Type{
+		Name: "Old movies",
+		PricingRule: models.PricingRule{
+			BaseDays:            5,
+			SurchargePercentage: 10,
+		},
+	}
+	genre := models.Genre{
+		Name: "Action",
+	}
+	movie1 := models.Movie{
+		Name:        "John Wick: Chapter 4",
+		Overview:    "With the price on his head ever increasing, John Wick uncovers a path to defeating The High Table.",
+		Price:       10,
+		TypeID:      1,
+		GenreID:     1,
+		ReleaseDate: "2023-03-22",
+	}
+	movie2 := models.Movie{
+		Name:        "Rambo",
+		Overview:    "When governments fail to act on behalf of captive missionaries, ex-Green Beret John James Rambo sets aside his peaceful existence to take action.",
+		Price:       10,
+		TypeID:      3,
+		GenreID:     1,
+		ReleaseDate: "2008-01-25",
+	}
+	user := models.User{
+		Surname:  "John",
+		Lastname: "Doe",
+	}
+	rent := models.Rent{
+		UserID:    1,
+		Total:     160,
+		StartDate: "2023-04-07",
+		EndDate:   "2023-04-15",
+		MovieRents: []models.MovieRent{
+			{MovieID: 1},
+			{MovieID: 2},
+		},
+	}
+	partial := models.Rent{
+		UserID:    1,
+		Total:     160,
+		StartDate: "2023-04-07",
+		EndDate:   "2023-04-15",
+		MovieRents: []models.MovieRent{
+			{MovieID: 1, ReturnDate: "2023-04-12"},
+			{MovieID: 2},
+		},
+	}
+
+	db.Create(&movieType1)
+	db.Create(&movieType2)
+	db.Create(&movieType3)
+	db.Create(&genre)
+	db.Create(&movie1)
+	db.Create(&movie2)
+	db.Create(&user)
+	db.Create(&rent)
+	db.Create(&partial)
+
+	rentRepository := repositories.NewRentRepository(db)
+	rentController := controllers.NewRentController(rentRepository)
+
+	requestBody := `{
+	  "return_date": "2023-04-17"
+	}`
+	request := httptest.NewRequest("POST", "/rent/1/return", strings.NewReader(requestBody))
+
+	request.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	router.POST("/rent/:id/return", rentController.Return)
+	router.ServeHTTP(rr, request)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var responseBody models.Response
+	if err = json.Unmarshal(rr.Body.Bytes(), &responseBody); err != nil {
+		t.Error(err)
+	}
+	if responseBody.Data == nil {
+		t.Error(responseBody.Message)
+	}
+
+	data, ok := responseBody.Data.(map[string]interface{})
+	if !ok {
+		t.Errorf("Bad data response structure")
+	}
+	if data["total"] != 202.0 {
+		t.Errorf("Total does not match")
+	}
+
+	var movieRents []models.MovieRent
+	db.Order("id").Find(&movieRents)
+	if movieRents[0].ReturnDate != "2023-04-17" || movieRents[0].LateFee != 20 {
+		t.Errorf("New release late fee does not match")
+	}
+	if movieRents[1].ReturnDate != "2023-04-17" || movieRents[1].LateFee != 22 {
+		t.Errorf("Old movie late fee does not match")
+	}
+
+	request = httptest.NewRequest("POST", "/rent/1/return", strings.NewReader(requestBody))
+	request.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+
+	if status := rr.Code; status != http.StatusConflict {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusConflict)
+	}
+
+	// Movies cannot be returned in the future nor before the last return.
+	tomorrow := time.Now().AddDate(0, 0, 1).Format(models.DateLayout)
+	for _, test := range []struct {
+		body string
+		code string
+	}{
+		{`{"return_date":"` + tomorrow + `"}`, "VALIDATION_FAILED"},
+		{`{"return_date":"2023-04-10"}`, "INVALID_RETURN_DATE"},
+	} {
+		request = httptest.NewRequest("POST", "/rent/2/return", strings.NewReader(test.body))
+		request.Header.Set("Content-Type", "application/json")
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, request)
+
+		var problem models.Problem
+		if err = json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+			t.Error(err)
+		}
+		if rr.Code != http.StatusBadRequest || problem.Code != test.code {
+			t.Errorf("%s: got %v %v want %v %v", test.body, rr.Code, problem.Code, http.StatusBadRequest, test.code)
+		}
+	}
+}
+
+func createRentFixtures(db *gorm.DB) {
+	movieType := models.Type{
+		Name: "New releases",
+	}
+	genre := models.Genre{
+		Name: "Action",
+	}
+	movie1 := models.Movie{
+		Name:        "John Wick: Chapter 4",
+		Overview:    "With the price on his head ever increasing, John Wick uncovers a path to defeating The High Table.",
+		Price:       10,
+		TypeID:      1,
+		GenreID:     1,
+		ReleaseDate: "2023-03-22",
+	}
+	movie2 := models.Movie{
+		Name:        "Shazam! Fury of the Gods",
+		Overview:    "Billy Batson and his foster siblings are forced to get back into action and fight the Daughters of Atlas.",
+		Price:       12,
+		TypeID:      1,
+		GenreID:     1,
+		ReleaseDate: "2023-03-16",
+	}
+	user1 := models.User{
+		Surname:  "John",
+		Lastname: "Doe",
+	}
+	user2 := models.User{
+		Surname:  "Jane",
+		Lastname: "Doe",
+	}
+	rent1 := models.Rent{
+		UserID:    1,
+		Total:     44,
+		StartDate: "2099-04-07",
+		EndDate:   "2099-04-09",
+		MovieRents: []models.MovieRent{
+			{MovieID: 1, CopyID: 1},
+			{MovieID: 2, CopyID: 2},
+		},
+	}
+	rent2 := models.Rent{
+		UserID:    2,
+		Total:     30,
+		StartDate: "2099-04-10",
+		EndDate:   "2099-04-13",
+		MovieRents: []models.MovieRent{
+			{MovieID: 1, CopyID: 1},
+		},
+	}
+
+	db.Create(&movieType)
+	db.Create(&genre)
+	db.Create(&movie1)
+	db.Create(&movie2)
+	db.Create(&user1)
+	db.Create(&user2)
+	db.Create(&models.Copy{MovieID: 1, Barcode: "WICK-001"})
+	db.Create(&models.Copy{MovieID: 2, Barcode: "SHAZAM-001"})
+	db.Create(&rent1)
+	db.Create(&rent2)
+}
+
+func TestGetRentByID(t *testing.T) {
+	router := gin.Default()
+	db, err := setupDB(models.Type{}, models.Genre{}, models.Movie{}, models.User{}, models.Copy{}, models.Rent{}, models.MovieRent{}, models.RentExtension{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = dropTable(db, models.Type{}, models.Genre{}, models.Movie{}, models.User{}, models.Copy{}, models.Rent{}, models.MovieRent{}, models.RentExtension{}); err != nil {
+			t.Error(err)
+		}
+	}()
+	createRentFixtures(db)
+
+	rentRepository := repositories.NewRentRepository(db)
+	rentController := controllers.NewRentController(rentRepository)
+
+	request := httptest.NewRequest("GET", "/rent/1", nil)
+	request.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	router.GET("/rent/:id", rentController.GetByID)
+	router.ServeHTTP(rr, request)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var responseBody models.Response
+	if err = json.Unmarshal(rr.Body.Bytes(), &responseBody); err != nil {
+		t.Error(err)
+	}
+	if responseBody.Data == nil {
+		t.Error(responseBody.Message)
+	}
+
+	data, ok := responseBody.Data.(map[string]interface{})
+	if !ok {
+		t.Errorf("Bad data response structure")
+	}
+	if data["total"] != 44.0 {
+		t.Errorf("Total does not match")
+	}
+	movies, ok := data["movies"].([]interface{})
+	if !ok || len(movies) != 2 {
+		t.Errorf("Movies do not match")
+	}
+	movieRents, ok := data["movie_rents"].([]interface{})
+	if !ok || len(movieRents) != 2 {
+		t.Errorf("Movie rents do not match")
+	}
+
+	request = httptest.NewRequest("GET", "/rent/3", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+	}
+}
+
+func TestGetAllRents(t *testing.T) {
+	router := gin.Default()
+	db, err := setupDB(models.Type{}, models.Genre{}, models.Movie{}, models.User{}, models.Copy{}, models.Rent{}, models.MovieRent{}, models.RentExtension{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = dropTable(db, models.Type{}, models.Genre{}, models.Movie{}, models.User{}, models.Copy{}, models.Rent{}, models.MovieRent{}, models.RentExtension{}); err != nil {
+			t.Error(err)
+		}
+	}()
+	createRentFixtures(db)
+
+	rentRepository := repositories.NewRentRepository(db)
+	rentController := controllers.NewRentController(rentRepository)
+
+	request := httptest.NewRequest("GET", "/rent", nil)
+	request.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	router.GET("/rent", rentController.GetAll)
+	router.ServeHTTP(rr, request)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var responseBody models.Response
+	if err = json.Unmarshal(rr.Body.Bytes(), &responseBody); err != nil {
+		t.Error(err)
+	}
+	if responseBody.Data == nil {
+		t.Error(responseBody.Message)
+	}
+
+	data, ok := responseBody.Data.([]interface{})
+	if !ok {
+		t.Errorf("Bad data response structure")
+	}
+	if len(data) != 2 {
+		t.Errorf("Unexpected number of rents: %v", len(data))
+	}
+}
+
+func TestGetRentsByUserID(t *testing.T) {
+	router := gin.Default()
+	db, err := setupDB(models.Type{}, models.Genre{}, models.Movie{}, models.User{}, models.Copy{}, models.Rent{}, models.MovieRent{}, models.RentExtension{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = dropTable(db, models.Type{}, models.Genre{}, models.Movie{}, models.User{}, models.Copy{}, models.Rent{}, models.MovieRent{}, models.RentExtension{}); err != nil {
+			t.Error(err)
+		}
+	}()
+	createRentFixtures(db)
+
+	rentRepository := repositories.NewRentRepository(db)
+	rentController := controllers.NewRentController(rentRepository)
+
+	request := httptest.NewRequest("GET", "/users/2/rents", nil)
+	request.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	router.GET("/users/:id/rents", rentController.GetByUserID)
+	router.ServeHTTP(rr, request)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var responseBody models.Response
+	if err = json.Unmarshal(rr.Body.Bytes(), &responseBody); err != nil {
+		t.Error(err)
+	}
+	if responseBody.Data == nil {
+		t.Error(responseBody.Message)
+	}
+
+	data, ok := responseBody.Data.([]interface{})
+	if !ok {
+		t.Errorf("Bad data response structure")
+	}
+	if len(data) != 1 {
+		t.Fatalf("Unexpected number of rents: %v", len(data))
+	}
+	rent, ok := data[0].(map[string]interface{})
+	if !ok || rent["user_id"] != 2.0 {
+		t.Errorf("User does not match")
+	}
+
+	request = httptest.NewRequest("GET", "/users/3/rents", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+	}
+}
+
+func TestCreateRentMovieUnavailable(t *testing.T) {
+	router := gin.Default()
+	db, err := setupDB(models.Type{}, models.Genre{}, models.Movie{}, models.User{}, models.Copy{}, models.Rent{}, models.MovieRent{}, models.RentExtension{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = dropTable(db, models.Type{}, models.Genre{}, models.Movie{}, models.User{}, models.Copy{}, models.Rent{}, models.MovieRent{}, models.RentExtension{}); err != nil {
+			t.Error(err)
+		}
+	}()
+	createRentFixtures(db)
+
+	rentRepository := repositories.NewRentRepository(db)
+	rentController := controllers.NewRentController(rentRepository)
+	router.POST("/rent/create", rentController.Create)
+
+	requestBody := `{
+      "user_id": 2,
+	  "movie_ids": [2],
+	  "start_date": "2099-04-08",
+      "end_date": "2099-04-12"
+	}`
+	request := httptest.NewRequest("POST", "/rent/create", strings.NewReader(requestBody))
+	request.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+
+	if status := rr.Code; status != http.StatusConflict {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusConflict)
+	}
+
+	requestBody = `{
+      "user_id": 2,
+	  "movie_ids": [1],
+	  "start_date": "2099-04-14",
+      "end_date": "2099-04-16"
+	}`
+	request = httptest.NewRequest("POST", "/rent/create", strings.NewReader(requestBody))
+	request.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var movieRent models.MovieRent
+	db.Last(&movieRent)
+	if movieRent.CopyID != 1 {
+		t.Errorf("Unexpected copy reserved: %v", movieRent.CopyID)
+	}
+}
+
+func TestCancelRent(t *testing.T) {
+	router := gin.Default()
+	db, err := setupDB(models.Type{}, models.Genre{}, models.Movie{}, models.User{}, models.Copy{}, models.Rent{}, models.MovieRent{}, models.RentExtension{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = dropTable(db, models.Type{}, models.Genre{}, models.Movie{}, models.User{}, models.Copy{}, models.Rent{}, models.MovieRent{}, models.RentExtension{}); err != nil {
+			t.Error(err)
+		}
+	}()
+	createRentFixtures(db)
+	// An active rent and an overdue one.
+	db.Create(&models.Rent{UserID: 1, Total: 20, StartDate: "2020-01-01", EndDate: "2099-01-01", MovieRents: []models.MovieRent{{MovieID: 1}}})
+	db.Create(&models.Rent{UserID: 1, Total: 20, StartDate: "2020-01-01", EndDate: "2020-01-03", MovieRents: []models.MovieRent{{MovieID: 1}}})
+
+	rentRepository := repositories.NewRentRepository(db)
+	rentController := controllers.NewRentController(rentRepository)
+	router.POST("/rent/create", rentController.Create)
+	router.POST("/rent/:id/return", rentController.Return)
+	router.POST("/rent/:id/cancel", rentController.Cancel)
+
+	post := func(path string, body string) (int, map[string]interface{}) {
+		request := httptest.NewRequest("POST", path, strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, request)
+		var response map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		return rr.Code, response
+	}
+
+	// Reserved rents are refunded in full.
+	status, response := post("/rent/1/cancel", "")
+	if status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v: %v", status, http.StatusOK, response)
+	}
+	data := response["data"].(map[string]interface{})
+	if data["status"] != models.RentCancelled || data["refund"] != 44.0 || data["total"] != 44.0 || data["cancel_date"] == "" {
+		t.Errorf("Unexpected cancelled rent: %v", data)
+	}
+	var rent models.Rent
+	db.First(&rent, 1)
+	if rent.Status != models.RentCancelled || rent.Refund != 44 {
+		t.Errorf("Cancellation not stored: %v %v", rent.Status, rent.Refund)
+	}
+
+	// Cancelled rents are final and free their copies.
+	for _, path := range []string{"/rent/1/cancel", "/rent/1/return"} {
+		if status, response = post(path, "{}"); status != http.StatusConflict || response["code"] != "INVALID_RENT_TRANSITION" {
+			t.Errorf("POST %s answered %v: %v", path, status, response)
+		}
+	}
+	if status, response = post("/rent/create", `{"user_id":2,"movie_ids":[2],"start_date":"2099-04-08","end_date":"2099-04-12"}`); status != http.StatusOK {
+		t.Errorf("Copy of the cancelled rent not freed: %v %v", status, response)
+	}
+
+	// Started rents are not refunded.
+	status, response = post("/rent/3/cancel", "")
+	if status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v: %v", status, http.StatusOK, response)
+	}
+	if data = response["data"].(map[string]interface{}); data["refund"] != 0.0 {
+		t.Errorf("Active rent refunded: %v", data)
+	}
+
+	// Overdue rents have to be returned.
+	if status, response = post("/rent/4/cancel", ""); status != http.StatusConflict || response["code"] != "INVALID_RENT_TRANSITION" {
+		t.Errorf("Overdue rent cancelled: %v %v", status, response)
+	}
+	if status, _ = post("/rent/9/cancel", ""); status != http.StatusNotFound {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+	}
+
+	rents, total, err := rentRepository.GetAll(context.Background(), &models.RentQuery{PageQuery: models.PageQuery{Page: 1, PageSize: 10}, Status: models.RentOverdue})
+	if err != nil || total != 1 || (*rents)[0].ID != 4 {
+		t.Errorf("Unexpected overdue rents: %v %v %v", rents, total, err)
+	}
+	updated, err := rentRepository.AdvanceStatuses(context.Background(), time.Now().Format(models.DateLayout))
+	if err != nil || updated != 1 {
+		t.Errorf("Unexpected rents updated: %v %v", updated, err)
+	}
+	var overdue models.Rent
+	db.First(&overdue, 4)
+	if overdue.Status != models.RentOverdue {
+		t.Errorf("Overdue status not stored: %v", overdue.Status)
+	}
+}
+
+func TestExtendRent(t *testing.T) {
+	router := gin.Default()
+	db, err := setupDB(models.Type{}, models.Genre{}, models.Movie{}, models.User{}, models.Copy{}, models.Rent{}, models.MovieRent{}, models.RentExtension{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = dropTable(db, models.Type{}, models.Genre{}, models.Movie{}, models.User{}, models.Copy{}, models.Rent{}, models.MovieRent{}, models.RentExtension{}); err != nil {
+			t.Error(err)
+		}
+	}()
+	createRentFixtures(db)
+	// An overdue rent and an active one whose first movie was returned.
+	db.Create(&models.Rent{UserID: 1, Total: 20, StartDate: "2020-01-01", EndDate: "2020-01-03", MovieRents: []models.MovieRent{{MovieID: 1}}})
+	db.Create(&models.Rent{UserID: 1, Total: 100, StartDate: "2020-01-01", EndDate: "2098-01-01", MovieRents: []models.MovieRent{
+		{MovieID: 1, CopyID: 1, ReturnDate: "2020-01-05"},
+		{MovieID: 2, CopyID: 2},
+	}})
+
+	rentRepository := repositories.NewRentRepository(db)
+	rentController := controllers.NewRentController(rentRepository)
+	router.POST("/rent/create", rentController.Create)
+	router.POST("/rent/:id/extend", rentController.Extend)
+
+	post := func(path string, body string) (int, map[string]interface{}) {
+		request := httptest.NewRequest("POST", path, strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, request)
+		var response map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		return rr.Code, response
+	}
+
+	status, response := post("/rent/2/extend", `{"end_date":"2099-04-15"}`)
+	if status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v: %v", status, http.StatusOK, response)
+	}
+	data := response["data"].(map[string]interface{})
+	if data["end_date"] != "2099-04-15" || data["total"] != 50.0 {
+		t.Errorf("Unexpected extended rent: %v", data)
+	}
+	extensions := data["extensions"].([]interface{})
+	if len(extensions) != 1 {
+		t.Fatalf("Unexpected extensions: %v", extensions)
+	}
+	extension := extensions[0].(map[string]interface{})
+	if extension["previous_end_date"] != "2099-04-13" || extension["end_date"] != "2099-04-15" || extension["amount"] != 20.0 {
+		t.Errorf("Unexpected extension: %v", extension)
+	}
+	var rent models.Rent
+	db.Preload("Extensions").First(&rent, 2)
+	if rent.EndDate != "2099-04-15" || rent.Total != 50 || len(rent.Extensions) != 1 {
+		t.Errorf("Extension not stored: %v %v %v", rent.EndDate, rent.Total, rent.Extensions)
+	}
+
+	// The copies are held for the extra days.
+	if status, response = post("/rent/create", `{"user_id":1,"movie_ids":[1],"start_date":"2099-04-14","end_date":"2099-04-16"}`); status != http.StatusConflict {
+		t.Errorf("Copy of the extended rent rented again: %v %v", status, response)
+	}
+	if status, response = post("/rent/1/extend", `{"end_date":"2099-04-11"}`); status != http.StatusConflict || response["code"] != "MOVIE_UNAVAILABLE" {
+		t.Errorf("Rent extended over another rent: %v %v", status, response)
+	}
+
+	// Returned movies are not extended nor charged.
+	status, response = post("/rent/4/extend", `{"end_date":"2098-01-05"}`)
+	if status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v: %v", status, http.StatusOK, response)
+	}
+	if data = response["data"].(map[string]interface{}); data["total"] != 148.0 {
+		t.Errorf("Unexpected total of the extended rent: %v", data)
+	}
+
+	for _, test := range []struct {
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{"/rent/4/extend", `{"end_date":"2098-01-05"}`, http.StatusBadRequest, "INVALID_EXTENSION_DATE"},
+		{"/rent/1/extend", `{"end_date":"2020-04-12"}`, http.StatusBadRequest, "VALIDATION_FAILED"},
+		{"/rent/1/extend", `{}`, http.StatusBadRequest, "VALIDATION_FAILED"},
+		{"/rent/3/extend", `{"end_date":"2099-04-12"}`, http.StatusConflict, "RENT_NOT_EXTENDABLE"},
+		{"/rent/9/extend", `{"end_date":"2099-04-12"}`, http.StatusNotFound, "RENT_NOT_FOUND"},
+	} {
+		if status, response = post(test.path, test.body); status != test.status || response["code"] != test.code {
+			t.Errorf("POST %s %s answered %v: %v", test.path, test.body, status, response)
+		}
+	}
+}
+
+func TestRentsLockCopies(t *testing.T) {
+	db, err := setupDB(models.Type{}, models.Genre{}, models.Movie{}, models.User{}, models.Copy{}, models.Rent{}, models.MovieRent{}, models.RentExtension{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = dropTable(db, models.Type{}, models.Genre{}, models.Movie{}, models.User{}, models.Copy{}, models.Rent{}, models.MovieRent{}, models.RentExtension{}); err != nil {
+			t.Error(err)
+		}
+	}()
+	createRentFixtures(db)
+
+	// SQLite drops the locking clause when building the SQL, look for it
+	// in the statements instead.
+	var locks []string
+	if err = db.Callback().Query().Before("gorm:query").Register("test:locks", func(tx *gorm.DB) {
+		if locking, ok := tx.Statement.Clauses["FOR"].Expression.(clause.Locking); ok && locking.Strength == "UPDATE" {
+			locks = append(locks, tx.Statement.Table)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	rentRepository := repositories.NewRentRepository(db)
+	ctx := context.Background()
+	if _, err = rentRepository.Create(ctx, &models.RentRequest{UserID: 1, MovieIDs: []int{2}, StartDate: "2099-05-01", EndDate: "2099-05-03"}, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = rentRepository.Extend(ctx, 3, "2099-05-05"); err != nil {
+		t.Fatal(err)
+	}
+	if len(locks) != 2 || locks[0] != "copies" || locks[1] != "copies" {
+		t.Errorf("Copies not locked before booking them: %v", locks)
+	}
+}
+
+func TestReturnRentConcurrently(t *testing.T) {
+	db, err := setupDB(models.Type{}, models.Genre{}, models.Movie{}, models.User{}, models.Copy{}, models.Rent{}, models.MovieRent{}, models.RentExtension{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = dropTable(db, models.Type{}, models.Genre{}, models.Movie{}, models.User{}, models.Copy{}, models.Rent{}, models.MovieRent{}, models.RentExtension{}); err != nil {
+			t.Error(err)
+		}
+	}()
+	createRentFixtures(db)
+	db.Create(&models.Rent{UserID: 1, Total: 160, StartDate: "2023-04-07", EndDate: "2023-04-15", MovieRents: []models.MovieRent{{MovieID: 1}, {MovieID: 2}}})
+
+	// Both returns load the rent before either of them writes, the single
+	// connection then runs their transactions one after the other.
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	var loads int32
+	var loaded sync.WaitGroup
+	if err = db.Callback().Query().After("gorm:query").Register("test:loaded", func(tx *gorm.DB) {
+		if tx.Statement.Table == "rents" && atomic.AddInt32(&loads, 1) <= 2 {
+			loaded.Done()
+			loaded.Wait()
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	rentRepository := repositories.NewRentRepository(db)
+	returnConcurrently := func(first []int, second []int) []error {
+		atomic.StoreInt32(&loads, 0)
+		loaded.Add(2)
+		var errs = make([]error, 2)
+		var done sync.WaitGroup
+		for i, movieIDs := range [][]int{first, second} {
+			done.Add(1)
+			go func(i int, movieIDs []int) {
+				defer done.Done()
+				_, errs[i] = rentRepository.Return(context.Background(), 3, &models.RentReturnRequest{MovieIDs: movieIDs, ReturnDate: "2023-04-17"})
+			}(i, movieIDs)
+		}
+		done.Wait()
+		return errs
+	}
+
+	// The same movie is returned once.
+	errs := returnConcurrently([]int{1}, []int{1})
+	if (errs[0] == nil) == (errs[1] == nil) {
+		t.Fatalf("Exactly one return of the same movie must succeed: %v", errs)
+	}
+	if err = errs[0]; err == nil {
+		err = errs[1]
+	}
+	if !errors.Is(err, utils.ErrMovieAlreadyReturned) {
+		t.Errorf("Unexpected error returning the movie twice: %v", err)
+	}
+
+	// Different movies keep both late fees.
+	db.Model(&models.MovieRent{}).Where("rent_id = ?", 3).Updates(map[string]interface{}{"return_date": "", "late_fee": 0})
+	db.Model(&models.Rent{}).Where("id = ?", 3).Update("total", 160)
+	if errs = returnConcurrently([]int{1}, []int{2}); errs[0] != nil || errs[1] != nil {
+		t.Fatalf("Unable to return the movies: %v", errs)
+	}
+	var rent models.Rent
+	db.First(&rent, 3)
+	if rent.Total != 204 || rent.Status != models.RentReturned {
+		t.Errorf("Late fees or status lost by the concurrent returns: %v %v", rent.Total, rent.Status)
+	}
+}
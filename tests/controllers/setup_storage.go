@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"sync/atomic"
 )
 
 func setupDB(models ...interface{}) (*gorm.DB, error) {
@@ -23,3 +24,26 @@ func dropTable(db *gorm.DB, tables ...interface{}) error {
 	}
 	return nil
 }
+
+// countQueries registers callbacks on db that count every statement it runs
+// and returns the counter.
+func countQueries(db *gorm.DB) (*int64, error) {
+	var count int64
+	increment := func(*gorm.DB) {
+		atomic.AddInt64(&count, 1)
+	}
+	callbacks := db.Callback()
+	for _, err := range []error{
+		callbacks.Query().After("gorm:query").Register("test:count_query", increment),
+		callbacks.Create().After("gorm:create").Register("test:count_create", increment),
+		callbacks.Update().After("gorm:update").Register("test:count_update", increment),
+		callbacks.Delete().After("gorm:delete").Register("test:count_delete", increment),
+		callbacks.Row().After("gorm:row").Register("test:count_row", increment),
+		callbacks.Raw().After("gorm:raw").Register("test:count_raw", increment),
+	} {
+		if err != nil {
+			return nil, fmt.Errorf("failed to register query counter: %v", err)
+		}
+	}
+	return &count, nil
+}
@@ -1,11 +1,16 @@
 package tests_controllers
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
 	"github.com/gin-gonic/gin"
 	"github/jorgemvv01/go-api/controllers"
 	"github/jorgemvv01/go-api/models"
 	"github/jorgemvv01/go-api/repositories"
+	"github/jorgemvv01/go-api/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -152,12 +157,12 @@ func TestGetAllTypes(t *testing.T) {
 
 func TestUpdateType(t *testing.T) {
 	router := gin.Default()
-	db, err := setupDB(models.Type{})
+	db, err := setupDB(models.Type{}, models.TypeAudit{}, models.Genre{}, models.Movie{})
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer func() {
-		if err = dropTable(db, models.Type{}); err != nil {
+		if err = dropTable(db, models.Type{}, models.TypeAudit{}, models.Genre{}, models.Movie{}); err != nil {
 			t.Error(err)
 		}
 	}()
@@ -203,14 +208,72 @@ func TestUpdateType(t *testing.T) {
 	}
 }
 
+func TestUpdateTypePricingRule(t *testing.T) {
+	router := gin.Default()
+	db, err := setupDB(models.Type{}, models.TypeAudit{}, models.Genre{}, models.Movie{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = dropTable(db, models.Type{}, models.TypeAudit{}, models.Genre{}, models.Movie{}); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	movieType := models.Type{
+		Name: "Regular movies",
+	}
+
+	db.Create(&movieType)
+
+	typeRepository := repositories.NewTypeRepository(db)
+	typeController := controllers.NewTypeController(typeRepository)
+
+	requestBody := `{"name":"Regular movies","base_days":3,"surcharge_percentage":15,"daily_cap":12.5}`
+	request := httptest.NewRequest("PUT", "/types/update/1", strings.NewReader(requestBody))
+	request.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+
+	router.PUT("/types/update/:id", typeController.Update)
+	router.ServeHTTP(rr, request)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	db.First(&movieType, 1)
+	if movieType.BaseDays != 3 || movieType.SurchargePercentage != 15 || movieType.DailyCap != 12.5 {
+		t.Errorf("Pricing rule does not match: %v", movieType.PricingRule)
+	}
+
+	var movies = []models.MovieSummary{
+		{Price: 10, Pricing: movieType.PricingRule},
+		{Price: 12, Pricing: movieType.PricingRule},
+	}
+	if total := utils.CalculateTotalRent(movies, 5); total != 10*3+11.5*2+12*3+12.5*2 {
+		t.Errorf("Total does not match: %v", total)
+	}
+
+	requestBody = `{"name":"Regular movies","surcharge_percentage":-5}`
+	request = httptest.NewRequest("PUT", "/types/update/1", strings.NewReader(requestBody))
+	request.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
+
 func TestDeleteType(t *testing.T) {
 	router := gin.Default()
-	db, err := setupDB(models.Type{})
+	db, err := setupDB(models.Type{}, models.TypeAudit{}, models.Genre{}, models.Movie{})
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer func() {
-		if err = dropTable(db, models.Type{}); err != nil {
+		if err = dropTable(db, models.Type{}, models.TypeAudit{}, models.Genre{}, models.Movie{}); err != nil {
 			t.Error(err)
 		}
 	}()
@@ -243,3 +306,160 @@ func TestDeleteType(t *testing.T) {
 	}
 
 }
+
+func TestDeleteTypeWithMovies(t *testing.T) {
+	router := gin.Default()
+	db, err := setupDB(models.Type{}, models.Genre{}, models.Movie{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = dropTable(db, models.Type{}, models.Genre{}, models.Movie{}); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	movieType1 := models.Type{
+		Name: "New releases",
+	}
+	movieType2 := models.Type{
+		Name: "4K collector",
+	}
+	genre := models.Genre{
+		Name: "Action",
+	}
+	movie := models.Movie{
+		Name:        "John Wick: Chapter 4",
+		Overview:    "With the price on his head ever increasing, John Wick uncovers a path to defeating The High Table.",
+		Price:       10,
+		TypeID:      1,
+		GenreID:     1,
+		ReleaseDate: "2023-03-22",
+	}
+	db.Create(&movieType1)
+	db.Create(&movieType2)
+	db.Create(&genre)
+	db.Create(&movie)
+
+	typeRepository := repositories.NewTypeRepository(db)
+	typeController := controllers.NewTypeController(typeRepository)
+	router.DELETE("/types/delete/:id", typeController.Delete)
+
+	request := httptest.NewRequest("DELETE", "/types/delete/1", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+
+	if status := rr.Code; status != http.StatusConflict {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusConflict)
+	}
+
+	request = httptest.NewRequest("DELETE", "/types/delete/1?reassign_to=3", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+	}
+
+	request = httptest.NewRequest("DELETE", "/types/delete/1?reassign_to=2", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	db.First(&movie, 1)
+	if movie.TypeID != 2 {
+		t.Errorf("Movie was not reassigned: %v", movie.TypeID)
+	}
+}
+
+func TestGetTypeAudits(t *testing.T) {
+	router := gin.Default()
+	db, err := setupDB(models.Type{}, models.TypeAudit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = dropTable(db, models.Type{}, models.TypeAudit{}); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	movieType := models.Type{
+		Name: "4K",
+	}
+	db.Create(&movieType)
+
+	typeRepository := repositories.NewTypeRepository(db)
+	typeController := controllers.NewTypeController(typeRepository)
+	router.PUT("/types/update/:id", typeController.Update)
+	router.GET("/types/:id/audits", typeController.GetAudits)
+
+	for _, requestBody := range []string{`{"name":"4K collector"}`, `{"name":"4K collector"}`} {
+		request := httptest.NewRequest("PUT", "/types/update/1", strings.NewReader(requestBody))
+		request.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, request)
+	}
+
+	request := httptest.NewRequest("GET", "/types/1/audits", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, request)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var responseBody models.Response
+	if err = json.Unmarshal(rr.Body.Bytes(), &responseBody); err != nil {
+		t.Error(err)
+	}
+	data, ok := responseBody.Data.([]interface{})
+	if !ok || len(data) != 1 {
+		t.Fatalf("Unexpected type audit: %v", responseBody.Data)
+	}
+	typeAudit, ok := data[0].(map[string]interface{})
+	if !ok || typeAudit["old_name"] != "4K" || typeAudit["new_name"] != "4K collector" {
+		t.Errorf("Type audit does not match")
+	}
+}
+
+func TestDeleteTypeLocksType(t *testing.T) {
+	db, err := setupDB(models.Type{}, models.Genre{}, models.Movie{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = dropTable(db, models.Type{}, models.Genre{}, models.Movie{}); err != nil {
+			t.Error(err)
+		}
+	}()
+	db.Create(&models.Type{Name: "New releases"})
+
+	// SQLite drops the locking clause when building the SQL, look for it
+	// in the statements instead. Movies created after the type is locked
+	// cannot be missed by the count that follows in the same transaction.
+	var statements []string
+	if err = db.Callback().Query().Before("gorm:query").Register("test:statements", func(tx *gorm.DB) {
+		var statement = tx.Statement.Table
+		if locking, ok := tx.Statement.Clauses["FOR"].Expression.(clause.Locking); ok && locking.Strength == "UPDATE" {
+			statement += " locked"
+		}
+		if _, ok := tx.Statement.ConnPool.(*sql.Tx); ok {
+			statement += " in transaction"
+		}
+		statements = append(statements, statement)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	typeRepository := repositories.NewTypeRepository(db)
+	if err = typeRepository.Delete(context.Background(), 1, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(statements) != 2 || statements[0] != "types locked in transaction" || statements[1] != "movies in transaction" {
+		t.Errorf("Movies not counted after locking the type: %v", statements)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github/jorgemvv01/go-api/controllers"
 	"github/jorgemvv01/go-api/models"
 	"github/jorgemvv01/go-api/repositories"
+	"github/jorgemvv01/go-api/utils"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -211,6 +212,70 @@ func TestUpdateUsers(t *testing.T) {
 	}
 }
 
+func TestPatchUser(t *testing.T) {
+	router := gin.Default()
+	db, err := setupDB(models.User{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = dropTable(db, models.User{}); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	user := models.User{
+		Surname:  "John",
+		Lastname: "Doe",
+		Username: "jdoe",
+		Role:     models.RoleCustomer,
+	}
+	db.Create(&user)
+
+	userRepository := repositories.NewUserRepository(db)
+	userController := controllers.NewUserController(userRepository)
+
+	requestBody := `{"lastname":"Villarreal","password":"secret123"}`
+	request := httptest.NewRequest("PATCH", "/users/1", strings.NewReader(requestBody))
+
+	request.Header.Set("Content-Type", "application/merge-patch+json")
+	rr := httptest.NewRecorder()
+
+	router.PATCH("/users/:id", userController.Patch)
+	router.ServeHTTP(rr, request)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var responseBody models.Response
+	if err = json.Unmarshal(rr.Body.Bytes(), &responseBody); err != nil {
+		t.Error(err)
+	}
+
+	data, ok := responseBody.Data.(map[string]interface{})
+
+	if !ok {
+		t.Fatalf("Bad data response structure")
+	}
+
+	if data["surname"] != "John" {
+		t.Errorf("Surname does not match")
+	}
+	if data["lastname"] != "Villarreal" {
+		t.Errorf("Lastname does not match")
+	}
+	if data["username"] != "jdoe" {
+		t.Errorf("Username does not match")
+	}
+
+	var patched models.User
+	db.First(&patched, user.ID)
+	if !utils.CheckPassword(patched.PasswordHash, "secret123") {
+		t.Errorf("Password was not patched")
+	}
+}
+
 func TestDeleteUser(t *testing.T) {
 	router := gin.Default()
 	db, err := setupDB(models.User{})
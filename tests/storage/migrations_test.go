@@ -0,0 +1,97 @@
+package tests_storage
+
+import (
+	"github/jorgemvv01/go-api/migrations"
+	"github/jorgemvv01/go-api/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrationsOnExistingDatabase(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "videoclub.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A database created by AutoMigrate whose types were seeded on each of
+	// two starts.
+	if err = db.AutoMigrate(&models.User{}, &models.Rent{}, &models.Type{}, &models.TypeAudit{}, &models.Genre{}, &models.Movie{}, &models.Copy{}, &models.MovieRent{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		for _, name := range []string{"New releases", "Regular movies", "Old movies"} {
+			db.Create(&models.Type{Name: name})
+		}
+	}
+	db.Create(&models.Genre{Name: "Action"})
+	db.Create(&models.Movie{Name: "John Wick: Chapter 4", Overview: "John Wick", Price: 10, TypeID: 5, GenreID: 1, ReleaseDate: "2023-03-22"})
+	db.Create(&models.TypeAudit{TypeID: 6, OldName: "Old", NewName: "Old movies"})
+	db.Create(&models.User{Surname: "John", Lastname: "Doe"})
+	for _, rent := range []models.Rent{
+		{UserID: 1, StartDate: "2020-01-01", EndDate: "2020-01-03", MovieRents: []models.MovieRent{{MovieID: 1, ReturnDate: "2020-01-03"}}},
+		{UserID: 1, StartDate: "2020-01-01", EndDate: "2020-01-03", MovieRents: []models.MovieRent{{MovieID: 1}}},
+		{UserID: 1, StartDate: "2020-01-01", EndDate: "2099-01-03", MovieRents: []models.MovieRent{{MovieID: 1}}},
+		{UserID: 1, StartDate: "2099-01-01", EndDate: "2099-01-03", MovieRents: []models.MovieRent{{MovieID: 1}}},
+	} {
+		db.Create(&rent)
+	}
+
+	applied, err := migrations.Up(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses, err := migrations.Statuses(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(statuses) {
+		t.Errorf("Unexpected number of applied migrations: got %v want %v", len(applied), len(statuses))
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			t.Errorf("Migration %d was not recorded", status.Version)
+		}
+	}
+	if applied, err = migrations.Up(db); err != nil || len(applied) != 0 {
+		t.Errorf("Migrations applied twice: %v %v", applied, err)
+	}
+
+	var types []models.Type
+	db.Unscoped().Order("id").Find(&types)
+	if len(types) != 3 {
+		t.Fatalf("Unexpected number of types: got %v want %v", len(types), 3)
+	}
+	if types[1].BaseDays != 3 || types[2].BaseDays != 5 {
+		t.Errorf("Pricing rules not backfilled: %v", types)
+	}
+	var movie models.Movie
+	db.First(&movie)
+	if movie.TypeID != 2 {
+		t.Errorf("Unexpected movie type: got %v want %v", movie.TypeID, 2)
+	}
+	var audit models.TypeAudit
+	db.First(&audit)
+	if audit.TypeID != 3 {
+		t.Errorf("Unexpected audit type: got %v want %v", audit.TypeID, 3)
+	}
+	var rents []models.Rent
+	db.Order("id").Find(&rents)
+	for i, status := range []string{models.RentReturned, models.RentOverdue, models.RentActive, models.RentReserved} {
+		if rents[i].Status != status {
+			t.Errorf("Unexpected status of rent %d: got %v want %v", rents[i].ID, rents[i].Status, status)
+		}
+	}
+
+	reverted, err := migrations.Down(db, len(statuses))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reverted) != len(statuses) || reverted[0].Version != statuses[len(statuses)-1].Version {
+		t.Errorf("Unexpected reverted migrations: %v", reverted)
+	}
+	if db.Migrator().HasTable(&models.Movie{}) {
+		t.Error("Movies table not dropped")
+	}
+}
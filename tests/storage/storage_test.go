@@ -0,0 +1,55 @@
+package tests_storage
+
+import (
+	"github/jorgemvv01/go-api/config"
+	"github/jorgemvv01/go-api/logging"
+	"github/jorgemvv01/go-api/migrations"
+	"github/jorgemvv01/go-api/models"
+	"github/jorgemvv01/go-api/storage"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+func TestConnectSQLite(t *testing.T) {
+	cfg := config.Default().Database
+	cfg.Driver = config.DriverSQLite
+	cfg.DSN = "file:" + filepath.Join(t.TempDir(), "videoclub.db") + "?_foreign_keys=on"
+
+	db, err := storage.Open(cfg, logging.New(config.LogLevelError, io.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = migrations.Up(db); err != nil {
+		t.Fatal(err)
+	}
+	// Seeding twice must not duplicate the types nor the administrator.
+	auth := config.AuthConfig{AdminUsername: "admin", AdminPassword: "secret"}
+	for i := 0; i < 2; i++ {
+		if err = storage.Seed(db, auth); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var count int64
+	if err = db.Model(&models.Type{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("Unexpected number of types: got %v want %v", count, 3)
+	}
+	if err = db.Model(&models.User{}).Where("role = ?", models.RoleAdmin).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("Unexpected number of admins: got %v want %v", count, 1)
+	}
+}
+
+func TestConnectUnsupportedDriver(t *testing.T) {
+	cfg := config.Default().Database
+	cfg.Driver = "oracle"
+	if _, err := storage.Open(cfg, logging.New(config.LogLevelError, io.Discard)); err == nil {
+		t.Error("Expected an error for an unsupported driver")
+	}
+}
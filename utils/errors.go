@@ -1,9 +1,88 @@
 package utils
 
-import "errors"
+import (
+	"fmt"
+	"github/jorgemvv01/go-api/models"
+	"net/http"
+)
 
-var ErrNotFound = errors.New("not found")
-var ErrTypeNotFound = errors.New("type not found")
-var ErrGenreNotFound = errors.New("genre not found")
-var ErrMovieNotFound = errors.New("movie not found")
-var ErrUserNotFound = errors.New("user not found")
+// Error is an error answered to the clients as a problem. Code is stable and
+// is what clients should branch on, Message is meant for humans and may be
+// reworded at any time.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Fields  []models.FieldError
+	kind    *Error
+}
+
+func NewError(status int, code string, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is reports whether target is the sentinel error e was derived from.
+func (e *Error) Is(target error) bool {
+	return e.kind != nil && target == e.kind
+}
+
+// Withf returns an error of the same kind with a more specific message.
+func (e *Error) Withf(format string, args ...interface{}) *Error {
+	derived := e.derive()
+	derived.Message = fmt.Sprintf(format, args...)
+	return derived
+}
+
+// WithFields returns an error of the same kind detailing the invalid fields.
+func (e *Error) WithFields(fields ...models.FieldError) *Error {
+	derived := e.derive()
+	derived.Fields = append(derived.Fields, fields...)
+	return derived
+}
+
+func (e *Error) derive() *Error {
+	derived := *e
+	if derived.kind == nil {
+		derived.kind = e
+	}
+	return &derived
+}
+
+var ErrNotFound = NewError(http.StatusNotFound, "NOT_FOUND", "not found")
+var ErrTypeNotFound = NewError(http.StatusNotFound, "TYPE_NOT_FOUND", "type not found")
+var ErrGenreNotFound = NewError(http.StatusNotFound, "GENRE_NOT_FOUND", "genre not found")
+var ErrMovieNotFound = NewError(http.StatusNotFound, "MOVIE_NOT_FOUND", "movie not found")
+var ErrUserNotFound = NewError(http.StatusNotFound, "USER_NOT_FOUND", "user not found")
+var ErrRentNotFound = NewError(http.StatusNotFound, "RENT_NOT_FOUND", "rent not found")
+var ErrMovieNotInRent = NewError(http.StatusNotFound, "MOVIE_NOT_IN_RENT", "movie not in rent")
+var ErrMovieAlreadyReturned = NewError(http.StatusConflict, "MOVIE_ALREADY_RETURNED", "movie already returned")
+var ErrInvalidReturnDate = NewError(http.StatusBadRequest, "INVALID_RETURN_DATE", "return date before start date or previous return")
+var ErrInvalidRentTransition = NewError(http.StatusConflict, "INVALID_RENT_TRANSITION", "the rent cannot move to the requested status")
+var ErrRentNotExtendable = NewError(http.StatusConflict, "RENT_NOT_EXTENDABLE", "the rent cannot be extended")
+var ErrInvalidExtensionDate = NewError(http.StatusBadRequest, "INVALID_EXTENSION_DATE", "end date not after the current end date")
+var ErrCopyNotFound = NewError(http.StatusNotFound, "COPY_NOT_FOUND", "copy not found")
+var ErrBarcodeAlreadyExists = NewError(http.StatusConflict, "BARCODE_ALREADY_EXISTS", "barcode already exists")
+var ErrCopyInUse = NewError(http.StatusConflict, "COPY_IN_USE", "copy is rented")
+var ErrMovieUnavailable = NewError(http.StatusConflict, "MOVIE_UNAVAILABLE", "movie unavailable")
+var ErrTypeInUse = NewError(http.StatusConflict, "TYPE_IN_USE", "type has movies")
+var ErrVersionMismatch = NewError(http.StatusPreconditionFailed, "VERSION_MISMATCH", "the resource was modified since it was read")
+var ErrIdempotencyKeyReused = NewError(http.StatusUnprocessableEntity, "IDEMPOTENCY_KEY_REUSED", "the idempotency key was used with another request")
+var ErrIdempotencyKeyInUse = NewError(http.StatusConflict, "IDEMPOTENCY_KEY_IN_USE", "a request with the same idempotency key is in progress")
+var ErrIdempotencyLeaseLost = NewError(http.StatusInternalServerError, "IDEMPOTENCY_LEASE_LOST", "the idempotency key was no longer reserved by the request")
+var ErrPreconditionRequired = NewError(http.StatusPreconditionRequired, "PRECONDITION_REQUIRED", "the If-Match header is required")
+var ErrInvalidSort = NewError(http.StatusBadRequest, "INVALID_SORT", "invalid sort field")
+var ErrInvalidCredentials = NewError(http.StatusUnauthorized, "INVALID_CREDENTIALS", "invalid username or password")
+var ErrInvalidToken = NewError(http.StatusUnauthorized, "INVALID_TOKEN", "invalid or expired token")
+var ErrUsernameAlreadyExists = NewError(http.StatusConflict, "USERNAME_ALREADY_EXISTS", "username already exists")
+var ErrValidation = NewError(http.StatusBadRequest, "VALIDATION_FAILED", "the request is invalid")
+var ErrMalformedRequest = NewError(http.StatusBadRequest, "MALFORMED_REQUEST", "the request body is not valid JSON")
+var ErrUnsupportedMediaType = NewError(http.StatusUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE", "unsupported content type")
+var ErrInvalidID = NewError(http.StatusBadRequest, "INVALID_ID", "invalid ID")
+var ErrUnauthorized = NewError(http.StatusUnauthorized, "UNAUTHORIZED", "authentication required")
+var ErrForbidden = NewError(http.StatusForbidden, "FORBIDDEN", "insufficient permissions")
+var ErrRouteNotFound = NewError(http.StatusNotFound, "ROUTE_NOT_FOUND", "route not found")
+var ErrInternal = NewError(http.StatusInternalServerError, "INTERNAL_ERROR", "an unexpected error occurred")
@@ -5,22 +5,30 @@ import "github/jorgemvv01/go-api/models"
 func CalculateTotalRent(movies []models.MovieSummary, days int) float64 {
 	var total float64
 	for _, movie := range movies {
-		var moviePrice = movie.Price
-		var totalMoviePrice float64
-		totalMoviePrice = (moviePrice) * float64(days)
-		switch movie.TypeID {
-		case 2:
-			if days > 3 {
-				totalMoviePrice = (moviePrice) * (3)
-				totalMoviePrice += (moviePrice + (moviePrice * 0.15)) * float64(days-3)
-			}
-		case 3:
-			if days > 5 {
-				totalMoviePrice = (moviePrice) * (5)
-				totalMoviePrice += (moviePrice + (moviePrice * 0.10)) * float64(days-5)
-			}
-		}
-		total += totalMoviePrice
+		total += calculateMovieRent(movie.Price, movie.Pricing, days)
 	}
 	return total
 }
+
+func calculateMovieRent(moviePrice float64, pricing models.PricingRule, days int) float64 {
+	if days <= pricing.BaseDays {
+		return moviePrice * float64(days)
+	}
+	var dailyPrice = moviePrice + (moviePrice * pricing.SurchargePercentage / 100)
+	if pricing.DailyCap > 0 && dailyPrice > pricing.DailyCap {
+		dailyPrice = pricing.DailyCap
+	}
+	var totalMoviePrice = moviePrice * float64(pricing.BaseDays)
+	totalMoviePrice += dailyPrice * float64(days-pricing.BaseDays)
+	return totalMoviePrice
+}
+
+// CalculateLateFee returns the extra amount owed for keeping a movie lateDays
+// past the rentDays originally charged, following the same type pricing.
+func CalculateLateFee(movie models.MovieSummary, rentDays int, lateDays int) float64 {
+	if lateDays <= 0 {
+		return 0
+	}
+	movies := []models.MovieSummary{movie}
+	return CalculateTotalRent(movies, rentDays+lateDays) - CalculateTotalRent(movies, rentDays)
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// SearchTerms splits a search query into lowercase words, dropping
+// punctuation so the terms can be used safely in LIKE patterns.
+func SearchTerms(q string) []string {
+	return strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// HighlightSnippet returns up to maxWords words of text starting a few words
+// before the first matched term, with every matching word wrapped in <mark>.
+// The words are HTML-escaped so that the snippet can be rendered as HTML.
+func HighlightSnippet(text string, terms []string, maxWords int) string {
+	words := strings.Fields(text)
+	var first = -1
+	for i, word := range words {
+		if matchesTerm(word, terms) {
+			first = i
+			break
+		}
+	}
+	var start = 0
+	if first > 3 {
+		start = first - 3
+	}
+	var end = start + maxWords
+	if end > len(words) {
+		end = len(words)
+	}
+
+	var snippet []string
+	for _, word := range words[start:end] {
+		var escaped = html.EscapeString(word)
+		if matchesTerm(word, terms) {
+			escaped = "<mark>" + escaped + "</mark>"
+		}
+		snippet = append(snippet, escaped)
+	}
+	var result = strings.Join(snippet, " ")
+	if start > 0 {
+		result = "..." + result
+	}
+	if end < len(words) {
+		result += "..."
+	}
+	return result
+}
+
+func matchesTerm(word string, terms []string) bool {
+	word = strings.ToLower(word)
+	for _, term := range terms {
+		if strings.Contains(word, term) {
+			return true
+		}
+	}
+	return false
+}
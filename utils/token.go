@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+	"github/jorgemvv01/go-api/models"
+	"strconv"
+	"time"
+)
+
+const AccessToken = "access"
+const RefreshToken = "refresh"
+
+type TokenClaims struct {
+	jwt.RegisteredClaims
+	Role      string `json:"role"`
+	TokenType string `json:"token_type"`
+}
+
+// UserID returns the ID of the user the token was issued to.
+func (tc *TokenClaims) UserID() uint {
+	id, _ := strconv.ParseUint(tc.Subject, 10, 64)
+	return uint(id)
+}
+
+type TokenService interface {
+	Generate(user models.User) (*models.TokenResponse, error)
+	Parse(token string, tokenType string) (*TokenClaims, error)
+}
+
+type tokenService struct {
+	secret     []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+func NewTokenService(secret []byte, accessTTL time.Duration, refreshTTL time.Duration) TokenService {
+	return &tokenService{
+		secret:     secret,
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+	}
+}
+
+func (ts *tokenService) Generate(user models.User) (*models.TokenResponse, error) {
+	accessToken, err := ts.sign(user, AccessToken, ts.accessTTL)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := ts.sign(user, RefreshToken, ts.refreshTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &models.TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(ts.accessTTL.Seconds()),
+	}, nil
+}
+
+func (ts *tokenService) sign(user models.User, tokenType string, ttl time.Duration) (string, error) {
+	var now = time.Now()
+	claims := TokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatUint(uint64(user.ID), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+		Role:      user.Role,
+		TokenType: tokenType,
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(ts.secret)
+}
+
+func (ts *tokenService) Parse(token string, tokenType string) (*TokenClaims, error) {
+	var claims TokenClaims
+	if _, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) {
+		return ts.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()})); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if claims.TokenType != tokenType || claims.UserID() == 0 {
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}